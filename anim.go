@@ -0,0 +1,251 @@
+package pixelart
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+// AnimOptions control how an Animation is put together.
+type AnimOptions struct {
+	// APNG writes an animated PNG instead of a GIF.
+	APNG bool
+	// Delay is how long each frame is shown.
+	Delay time.Duration
+	// Hold is how long the final frame stays up before the animation loops.
+	// 0 shows it for Delay like the others.
+	Hold time.Duration
+	// Scale resizes the frames; 0 leaves them at full size.
+	Scale float64
+	// Dither diffuses the error of reducing GIF frames to 256 colours.
+	Dither bool
+}
+
+// Animation gathers frames of a fill in memory to be encoded as one
+// animated GIF or PNG.
+type Animation struct {
+	opts AnimOptions
+
+	gif gif.GIF
+
+	ihdr   []byte
+	frames [][]byte // the zlib stream of each frame
+}
+
+func NewAnimation(opts AnimOptions) *Animation {
+	return &Animation{opts: opts}
+}
+
+// AnimFrameHook returns a FrameHook that adds frames to a so that there are
+// about frames of them over a total pixel fill. The first error adding a
+// frame is kept in *err.
+func AnimFrameHook(a *Animation, frames, total int, err *error) FrameHook {
+	return FrameHook{
+		Every: maxint(1, total/maxint(1, frames)),
+		Frame: func(pic *image.RGBA, filled int) {
+			if *err == nil {
+				*err = a.AddFrame(pic)
+			}
+		},
+	}
+}
+
+// scaleImage resizes pic by scale, which is taken as 1 if it's 0.
+func scaleImage(pic image.Image, scale float64) *image.RGBA {
+	b := pic.Bounds()
+	if scale <= 0 {
+		scale = 1
+	}
+	if rgba, ok := pic.(*image.RGBA); ok && scale == 1 {
+		return rgba
+	}
+	w := maxint(1, int(float64(b.Dx())*scale+0.5))
+	h := maxint(1, int(float64(b.Dy())*scale+0.5))
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if w == b.Dx() && h == b.Dy() {
+		draw.Draw(dst, dst.Bounds(), pic, b.Min, draw.Src)
+	} else {
+		draw.BiLinear.Scale(dst, dst.Bounds(), pic, b, draw.Src, nil)
+	}
+	return dst
+}
+
+// Thumbnail shrinks pic to fit in a square of size pixels, keeping its
+// aspect. Pictures that already fit come back at their own size.
+func Thumbnail(pic image.Image, size int) *image.RGBA {
+	b := pic.Bounds()
+	return scaleImage(pic, math.Min(1, float64(size)/float64(maxint(b.Dx(), b.Dy()))))
+}
+
+func (a *Animation) AddFrame(pic image.Image) error {
+	frame := scaleImage(pic, a.opts.Scale)
+
+	if !a.opts.APNG {
+		a.gif.Image = append(a.gif.Image, quantize(frame, medianCut(frame, 256), a.opts.Dither))
+		a.gif.Delay = append(a.gif.Delay, gifDelay(a.opts.Delay))
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := enc.Encode(&buf, frame); err != nil {
+		return err
+	}
+	ihdr, idat, err := pngImageData(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	if a.ihdr == nil {
+		a.ihdr = ihdr
+	} else if !bytes.Equal(a.ihdr, ihdr) {
+		return errors.New("animation frames differ in size or colour type")
+	}
+	a.frames = append(a.frames, idat)
+	return nil
+}
+
+func (a *Animation) Len() int {
+	if a.opts.APNG {
+		return len(a.frames)
+	}
+	return len(a.gif.Image)
+}
+
+func (a *Animation) Encode(w io.Writer) error {
+	if !a.opts.APNG {
+		if len(a.gif.Image) == 0 {
+			return errors.New("animation has no frames")
+		}
+		g := a.gif
+		g.Delay = append([]int(nil), a.gif.Delay...)
+		if a.opts.Hold > 0 {
+			g.Delay[len(g.Delay)-1] = gifDelay(a.opts.Hold)
+		}
+		return gif.EncodeAll(w, &g)
+	}
+	return a.encodeAPNG(w)
+}
+
+// gifDelay converts d to the hundredths of a second GIF frames are timed in.
+func gifDelay(d time.Duration) int {
+	return maxint(1, int(d/(10*time.Millisecond)))
+}
+
+// pngImageData splits an encoded PNG into its header and image data.
+func pngImageData(b []byte) (ihdr, idat []byte, err error) {
+	if len(b) < 8 {
+		return nil, nil, errors.New("short PNG")
+	}
+	for b = b[8:]; len(b) >= 12; {
+		n := int(binary.BigEndian.Uint32(b))
+		if len(b) < 12+n {
+			return nil, nil, errors.New("truncated PNG chunk")
+		}
+		typ, data := string(b[4:8]), b[8:8+n]
+		switch typ {
+		case "IHDR":
+			ihdr = data
+		case "IDAT":
+			idat = append(idat, data...)
+		}
+		b = b[12+n:]
+	}
+	if ihdr == nil || idat == nil {
+		return nil, nil, errors.New("PNG has no image data")
+	}
+	return ihdr, idat, nil
+}
+
+func writePNGChunk(w io.Writer, typ string, data []byte) error {
+	var head [8]byte
+	binary.BigEndian.PutUint32(head[:4], uint32(len(data)))
+	copy(head[4:], typ)
+	crc := crc32.NewIEEE()
+	crc.Write(head[4:])
+	crc.Write(data)
+	var tail [4]byte
+	binary.BigEndian.PutUint32(tail[:], crc.Sum32())
+
+	for _, b := range [][]byte{head[:], data, tail[:]} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apngDelay gives d as the fraction of a second that fcTL chunks use.
+func apngDelay(d time.Duration) (num, den uint16) {
+	ms := d / time.Millisecond
+	if ms > 65535 {
+		// the longest we can say in milliseconds, so try hundredths
+		cs := minint(int(d/(10*time.Millisecond)), 65535)
+		return uint16(cs), 100
+	}
+	return uint16(ms), 1000
+}
+
+func (a *Animation) encodeAPNG(w io.Writer) error {
+	if len(a.frames) == 0 {
+		return errors.New("animation has no frames")
+	}
+	if _, err := io.WriteString(w, "\x89PNG\r\n\x1a\n"); err != nil {
+		return err
+	}
+	if err := writePNGChunk(w, "IHDR", a.ihdr); err != nil {
+		return err
+	}
+
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl, uint32(len(a.frames)))
+	// 0 plays means loop forever
+	if err := writePNGChunk(w, "acTL", actl); err != nil {
+		return err
+	}
+
+	var seq uint32
+	for i, data := range a.frames {
+		delay := a.opts.Delay
+		if i == len(a.frames)-1 && a.opts.Hold > 0 {
+			delay = a.opts.Hold
+		}
+		num, den := apngDelay(delay)
+
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], seq)
+		copy(fctl[4:12], a.ihdr[0:8]) // width and height
+		binary.BigEndian.PutUint16(fctl[20:], num)
+		binary.BigEndian.PutUint16(fctl[22:], den)
+		// offsets, dispose and blend ops are all 0
+		if err := writePNGChunk(w, "fcTL", fctl); err != nil {
+			return err
+		}
+		seq++
+
+		var err error
+		if i == 0 {
+			// the first frame doubles as the still image
+			err = writePNGChunk(w, "IDAT", data)
+		} else {
+			fdat := make([]byte, 4+len(data))
+			binary.BigEndian.PutUint32(fdat, seq)
+			copy(fdat[4:], data)
+			err = writePNGChunk(w, "fdAT", fdat)
+			seq++
+		}
+		if err != nil {
+			return fmt.Errorf("writing frame %d: %v", i, err)
+		}
+	}
+	return writePNGChunk(w, "IEND", nil)
+}
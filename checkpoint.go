@@ -0,0 +1,293 @@
+package pixelart
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const checkpointVersion = 1
+
+// checkpointArgs mirrors the GenerateArgs fields that decide what the
+// finished image looks like, so a resumed run can carry on with them.
+type checkpointArgs struct {
+	ChanSize    int32
+	Blur        int32
+	ColourBasis ColourBasis
+	Echospace   float64
+	FlipDraw    bool
+	DrawIR      bool
+
+	Name string
+	Tag  string
+
+	SeedImagePath     string
+	SeedRejectionRate float64
+	ReseedDupes       bool
+	ChromaColour      int
+	PalettePath       string
+
+	StartRed   int
+	StartGreen int
+	StartBlue  int
+	StartX     int
+	StartY     int
+	// Seeds as FormatSeeds has them; they're only kept for the metadata,
+	// the canvas has them already
+	Seeds string
+
+	Height int
+	Width  int
+
+	UpdateFreq int32
+	RNGSeed    int64
+}
+
+// checkpoint is everything a deterministic run needs to continue from the
+// middle of fillPixelArray and end up with the same image.
+type checkpoint struct {
+	Version int
+	Args    checkpointArgs
+
+	Count int32
+	IRTag int32
+
+	// Pixels holds red, green, blue and a flag byte for each pixel of the
+	// canvas, column by column.
+	Pixels []byte
+
+	// colourspace
+	Cube        []byte // one bit per colour, in the basis order
+	ColourCount int32
+	Released    int32
+	Unavailable int32
+	Optimised   bool
+	Echo        []byte // red, green, blue triples, oldest first
+	XCounts     [256]int32
+	YCounts     [256]int32
+	ZCounts     [256]int32
+
+	Frontier []image.Point
+	RNG      uint64
+
+	// only when the run is recording its fill order
+	FillOrder []uint32
+	FillSeeds int
+}
+
+const (
+	pixelFilled = 1 << iota
+	pixelQueued
+)
+
+func newCheckpoint(args GenerateArgs, count, irTag int32, pArray *PixelArray, cspace Colourspace, front *frontier) *checkpoint {
+	cp := &checkpoint{
+		Version: checkpointVersion,
+		Args: checkpointArgs{
+			ChanSize:          args.ChanSize,
+			Blur:              args.Blur,
+			ColourBasis:       args.ColourBasis,
+			Echospace:         args.Echospace,
+			FlipDraw:          args.FlipDraw,
+			DrawIR:            args.DrawIR,
+			Name:              args.Name,
+			Tag:               args.Tag,
+			SeedImagePath:     args.SeedImagePath,
+			SeedRejectionRate: args.SeedRejectionRate,
+			ReseedDupes:       args.ReseedDupes,
+			ChromaColour:      args.ChromaColour,
+			PalettePath:       args.PalettePath,
+			StartRed:          args.StartRed,
+			StartGreen:        args.StartGreen,
+			StartBlue:         args.StartBlue,
+			StartX:            args.StartX,
+			StartY:            args.StartY,
+			Seeds:             FormatSeeds(args.Seeds),
+			Height:            args.Height,
+			Width:             args.Width,
+			UpdateFreq:        args.UpdateFreq,
+			RNGSeed:           args.RNGSeed,
+		},
+		Count: count,
+		IRTag: irTag,
+	}
+
+	cp.Pixels = make([]byte, 0, args.Width*args.Height*4)
+	for x := 0; x < args.Width; x++ {
+		for y := 0; y < args.Height; y++ {
+			p := &(*pArray)[x][y]
+			var flags byte
+			if p.Filled {
+				flags |= pixelFilled
+			}
+			if p.Queued {
+				flags |= pixelQueued
+			}
+			cp.Pixels = append(cp.Pixels, p.Colour.red, p.Colour.green, p.Colour.blue, flags)
+		}
+	}
+
+	cspace.saveState(cp)
+
+	if args.FillOrder != nil {
+		cp.FillOrder = args.FillOrder.Index
+		cp.FillSeeds = args.FillOrder.Seeds
+	}
+
+	cp.Frontier = append([]image.Point(nil), front.Points()...)
+	cp.RNG = front.src.state
+	return cp
+}
+
+// apply copies the checkpointed parameters over args, keeping the callbacks,
+// checkpoint settings and any output name of the resuming run.
+func (cp *checkpoint) apply(args GenerateArgs) GenerateArgs {
+	a := cp.Args
+	args.ChanSize = a.ChanSize
+	args.Blur = a.Blur
+	args.ColourBasis = a.ColourBasis
+	args.Echospace = a.Echospace
+	args.FlipDraw = a.FlipDraw
+	args.DrawIR = a.DrawIR
+	if args.Name == "" {
+		args.Name = a.Name
+	}
+	args.Tag = a.Tag
+	args.SeedImage = nil
+	args.SeedImagePath = a.SeedImagePath
+	args.SeedRejectionRate = a.SeedRejectionRate
+	args.ReseedDupes = a.ReseedDupes
+	args.ChromaColour = a.ChromaColour
+	// the palette is already in the checkpointed colourspace
+	args.Palette = nil
+	args.PalettePath = a.PalettePath
+	args.StartRed = a.StartRed
+	args.StartGreen = a.StartGreen
+	args.StartBlue = a.StartBlue
+	args.StartX = a.StartX
+	args.StartY = a.StartY
+	args.Seeds, _ = ParseSeeds(a.Seeds)
+	args.Height = a.Height
+	args.Width = a.Width
+	args.UpdateFreq = a.UpdateFreq
+	args.RNGSeed = a.RNGSeed
+	return args
+}
+
+// restore loads the checkpointed canvas, colourspace and frontier.
+func (cp *checkpoint) restore(pArray *PixelArray, cspace Colourspace, front *frontier) {
+	i := 0
+	for x := 0; x < cp.Args.Width; x++ {
+		for y := 0; y < cp.Args.Height; y++ {
+			p := &(*pArray)[x][y]
+			p.Colour = Colour24{cp.Pixels[i], cp.Pixels[i+1], cp.Pixels[i+2]}
+			p.Filled = cp.Pixels[i+3]&pixelFilled != 0
+			p.Queued = cp.Pixels[i+3]&pixelQueued != 0
+			i += 4
+		}
+	}
+
+	cspace.loadState(cp)
+
+	front.points = append(front.points[:0], cp.Frontier...)
+	front.head = 0
+	front.src.state = cp.RNG
+}
+
+// writeCheckpoint saves cp next to path and renames it into place, so an
+// interrupted write never clobbers the previous checkpoint.
+func writeCheckpoint(cp *checkpoint, path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	zw, err := gzip.NewWriterLevel(tmp, gzip.BestSpeed)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = gob.NewEncoder(zw).Encode(cp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func readCheckpoint(path string) (*checkpoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	cp := new(checkpoint)
+	if err = gob.NewDecoder(zr).Decode(cp); err != nil {
+		return nil, err
+	}
+	if cp.Version != checkpointVersion {
+		return nil, fmt.Errorf("checkpoint %s has version %d, expected %d", path, cp.Version, checkpointVersion)
+	}
+	corrupt := fmt.Errorf("checkpoint %s is corrupt", path)
+	w, h := cp.Args.Width, cp.Args.Height
+	if w < 1 || h < 1 || w > MaxWidth || h > MaxHeight || len(cp.Pixels) != w*h*4 ||
+		len(cp.Cube) != 256*256*256/8 || cp.FillOrder != nil && len(cp.FillOrder) != w*h {
+		return nil, corrupt
+	}
+	// the echo queue holds colours already placed, and only if there's
+	// echospacing
+	if len(cp.Echo)%3 != 0 || len(cp.Echo)/3 > int(cp.ColourCount) || cp.Args.Echospace == 0 && len(cp.Echo) > 0 {
+		return nil, corrupt
+	}
+	for _, pt := range cp.Frontier {
+		if !pt.In(image.Rect(0, 0, w, h)) {
+			return nil, corrupt
+		}
+	}
+	return cp, nil
+}
+
+// checkpointer decides when fillPixelArray should write a checkpoint.
+type checkpointer struct {
+	path     string
+	interval time.Duration
+	last     time.Time
+	logf     func(format string, v ...interface{})
+}
+
+func (c *checkpointer) Due(count int32) bool {
+	// checking the clock on every pixel is wasteful
+	return c != nil && count%4096 == 0 && time.Since(c.last) >= c.interval
+}
+
+func (c *checkpointer) Save(cp *checkpoint) {
+	var time_format = "15:04:05"
+	start := time.Now()
+	if err := writeCheckpoint(cp, c.path); err != nil {
+		// a failed checkpoint shouldn't cost the run itself
+		c.logf("Checkpoint failed: %v", err)
+	} else {
+		c.logf("[%s] Checkpoint written to %s in %s", start.Format(time_format), c.path, time.Since(start).String())
+	}
+	c.last = time.Now()
+}
@@ -0,0 +1,78 @@
+package pixelart
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResumeMatchesUninterruptedRun(t *testing.T) {
+	base := NewGenerateArgs()
+	base.Width, base.Height = 128, 128
+	base.RNGSeed = 7
+	base.Echospace = 0.001
+
+	want, err := Generate(context.Background(), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checkpoint at every chance, and stop part way through
+	path := filepath.Join(t.TempDir(), "run.checkpoint")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupted := base
+	interrupted.CheckpointPath = path
+	interrupted.CheckpointInterval = time.Nanosecond
+	interrupted.Progress = func(p Progress) {
+		if p.Filled > 10000 {
+			cancel()
+		}
+	}
+	if _, err = Generate(ctx, interrupted); !errors.Is(err, context.Canceled) {
+		t.Fatalf("interrupted run returned %v, want it cancelled", err)
+	}
+
+	resumed := NewGenerateArgs()
+	resumed.ResumePath = path
+	got, err := Generate(context.Background(), resumed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("resumed run is %v, want %v", got.Bounds(), want.Bounds())
+	}
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 128; x++ {
+			if g, w := got.At(x, y), want.At(x, y); g != w {
+				t.Fatalf("resumed run has %v at %d,%d, want %v", g, x, y, w)
+			}
+		}
+	}
+}
+
+func TestReadCheckpointRejectsBadEcho(t *testing.T) {
+	for _, echo := range [][]byte{
+		{1, 2},             // not a whole colour
+		{1, 2, 3, 4, 5, 6}, // more colours than were placed
+	} {
+		cp := &checkpoint{
+			Version:     checkpointVersion,
+			Args:        checkpointArgs{Width: 8, Height: 8, Echospace: 0.5},
+			Pixels:      make([]byte, 8*8*4),
+			Cube:        make([]byte, 256*256*256/8),
+			ColourCount: 1,
+			Echo:        echo,
+		}
+		path := filepath.Join(t.TempDir(), "bad.checkpoint")
+		if err := writeCheckpoint(cp, path); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readCheckpoint(path); err == nil {
+			t.Errorf("read a checkpoint with %d echo bytes for 1 colour", len(echo))
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	stdmath "math"
+
+	"github.com/google/gxui"
+	"github.com/google/gxui/math"
+)
+
+// the side of each picture in the comparison window
+const compareSize = 512
+
+// compareRuns opens two runs side by side. Both pictures are zoomed and
+// panned together, so the same part of each is always in view; clicking
+// either centres both there and scrolling zooms.
+func compareRuns(theme gxui.Theme, a, b *historyEntry) error {
+	pics := make([]image.Image, 2)
+	notes := make([]string, 2)
+	for i, e := range []*historyEntry{a, b} {
+		pic, full, err := History.picture(e)
+		if err != nil {
+			return fmt.Errorf("run #%d: %v", e.ID, err)
+		}
+		pics[i] = pic
+		notes[i] = e.String()
+		if !full {
+			notes[i] += " (thumbnail only, the image is gone)"
+		}
+	}
+
+	// the same scale for both, so a picture pixel covers as much of each;
+	// both start from 0,0, so the larger's view takes in the smaller
+	var largest image.Point
+	for _, pic := range pics {
+		size := pic.Bounds().Size()
+		largest = image.Pt(maxint(largest.X, size.X), maxint(largest.Y, size.Y))
+	}
+	home := fitView(largest, compareSize)
+	v := home
+
+	window := theme.CreateWindow(2*compareSize+40, compareSize+120, fmt.Sprintf("Run #%d and run #%d", a.ID, b.ID))
+	layout := theme.CreateLinearLayout()
+	layout.SetDirection(gxui.TopToBottom)
+	window.AddChild(layout)
+
+	row := theme.CreateLinearLayout()
+	row.SetDirection(gxui.LeftToRight)
+	layout.AddChild(row)
+	views := make([]gxui.Image, 2)
+	zoomLabel := theme.CreateLabel()
+
+	redraw := func() {
+		for i, pic := range pics {
+			views[i].SetTexture(Driver.CreateTexture(renderView(pic, v, compareSize), 1))
+		}
+		zoomLabel.SetText(fmt.Sprintf("%.3gx at %d,%d", v.zoom, v.centre.X, v.centre.Y))
+	}
+	zoomBy := func(f float64) {
+		v.zoom = stdmath.Max(home.zoom/4, stdmath.Min(64, v.zoom*f))
+		redraw()
+	}
+
+	for i := range pics {
+		column := theme.CreateLinearLayout()
+		column.SetDirection(gxui.TopToBottom)
+		label := theme.CreateLabel()
+		label.SetText(notes[i])
+		column.AddChild(label)
+
+		view := theme.CreateImage()
+		view.SetExplicitSize(math.Size{W: compareSize, H: compareSize})
+		view.OnClick(func(ev gxui.MouseEvent) {
+			if p, ok := view.PixelAt(ev.Point); ok {
+				v.centre = v.toImage(image.Pt(p.X, p.Y), compareSize)
+				redraw()
+			}
+		})
+		view.OnMouseScroll(func(ev gxui.MouseEvent) {
+			if ev.ScrollY > 0 {
+				zoomBy(2)
+			} else if ev.ScrollY < 0 {
+				zoomBy(0.5)
+			}
+		})
+		views[i] = view
+		column.AddChild(view)
+		row.AddChild(column)
+	}
+
+	controls := zoomButtons(theme, zoomBy,
+		func() { v.zoom = 1; redraw() },
+		func() { v = home; redraw() })
+	controls.AddChild(zoomLabel)
+	layout.AddChild(controls)
+
+	redraw()
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"time"
+
+	"github.com/Kapura/pixelart"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+)
+
+// animFlags are the animation settings shared by the commands that can
+// make one.
+type animFlags struct {
+	path   string
+	frames int
+	delay  time.Duration
+	hold   time.Duration
+	scale  float64
+	dither bool
+}
+
+func (a *animFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&a.path, "anim", "", "also save the filling process as an animated .gif, or an APNG if the name ends in .png or .apng")
+	fs.IntVar(&a.frames, "anim-frames", 100, "number of frames in the animation")
+	fs.DurationVar(&a.delay, "anim-delay", 50*time.Millisecond, "time each animation frame is shown")
+	fs.DurationVar(&a.hold, "anim-hold", 0, "time the final frame is held before the animation loops. 0 for no hold")
+	fs.Float64Var(&a.scale, "anim-scale", 0.125, "scale of the animation frames relative to the image")
+	fs.BoolVar(&a.dither, "anim-dither", false, "dither GIF frames down to their palettes")
+}
+
+func (a *animFlags) apply(save *saveOptions) error {
+	if a.path == "" {
+		return nil
+	}
+	if err := save.setAnimPath(a.path); err != nil {
+		return err
+	}
+	save.animFrames = a.frames
+	save.anim.Delay = a.delay
+	save.anim.Hold = a.hold
+	save.anim.Scale = a.scale
+	save.anim.Dither = a.dither
+	return nil
+}
+
+// termFlags are the settings of the terminal preview.
+type termFlags struct {
+	on     bool
+	width  int
+	frames int
+}
+
+func (t *termFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&t.on, "preview", false, "draw the canvas in the terminal as it fills, in 24-bit colour, with a progress bar")
+	fs.IntVar(&t.width, "preview-width", 0, "width of the terminal preview in columns. 0 takes it from $COLUMNS, or 80")
+	fs.IntVar(&t.frames, "preview-frames", 100, "number of times the terminal preview is redrawn")
+}
+
+func (t *termFlags) apply(save *saveOptions) {
+	if t.on {
+		save.preview = termOptions{width: t.width, frames: maxint(1, t.frames)}
+	}
+}
+
+// snapFlags are the settings of the snapshots taken as a run goes.
+type snapFlags struct {
+	fractions int
+	pixels    int
+	interval  time.Duration
+	dir       string
+	name      string
+	scale     float64
+	keep      int
+}
+
+func (f *snapFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&f.fractions, "snapshots", 0, "save this many snapshots of the canvas, evenly through the run")
+	fs.IntVar(&f.pixels, "snapshot-pixels", 0, "save a snapshot every this many pixels filled")
+	fs.DurationVar(&f.interval, "snapshot-interval", 0, "save a snapshot every this long")
+	fs.StringVar(&f.dir, "snapshot-dir", "", "directory to save snapshots in. Defaults to the working directory")
+	fs.StringVar(&f.name, "snapshot-name", "{tag}.{n}", "snapshot file name, without the extension. {tag}, {n}, {filled} and {percent} are filled in")
+	fs.Float64Var(&f.scale, "snapshot-scale", 1, "scale of the snapshots relative to the image")
+	fs.IntVar(&f.keep, "snapshot-keep", 0, "keep only this many of the newest snapshots. 0 keeps them all")
+}
+
+func (f *snapFlags) apply(save *saveOptions) {
+	save.snapshots = pixelart.SnapshotOptions{
+		Fractions: f.fractions,
+		Pixels:    f.pixels,
+		Interval:  f.interval,
+		Dir:       f.dir,
+		Name:      f.name,
+		Scale:     f.scale,
+		Keep:      f.keep,
+	}
+}
+
+// loadArgsImages loads the seed image and palette that args names. The
+// seed image sets the size, as it does on the command line.
+func loadArgsImages(args *pixelart.GenerateArgs) (err error) {
+	if args.SeedImagePath != "" {
+		if args.SeedImage, err = loadImage(args.SeedImagePath); err != nil {
+			return &pixelart.FieldError{Field: "seed-image", Message: err.Error()}
+		}
+		args.Width = args.SeedImage.Bounds().Max.X
+		args.Height = args.SeedImage.Bounds().Max.Y
+	}
+	if args.PalettePath != "" {
+		if args.Palette, err = loadImage(args.PalettePath); err != nil {
+			return &pixelart.FieldError{Field: "palette", Message: err.Error()}
+		}
+	}
+	return nil
+}
+
+// loadImage decodes a PNG, JPEG, BMP or TIFF file.
+func loadImage(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	pic, _, err := image.Decode(file)
+	return pic, err
+}
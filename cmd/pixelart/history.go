@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Kapura/pixelart"
+	"github.com/google/gxui"
+	"github.com/google/gxui/math"
+)
+
+// historyEntry is a run made from the window: what it was made with, a
+// thumbnail of what it made and where the image went.
+type historyEntry struct {
+	ID       int               `json:"id"`
+	Time     time.Time         `json:"time"`
+	Settings pixelart.Settings `json:"settings"`
+	// Output is the saved image; stopped runs weren't saved
+	Output  string        `json:"output,omitempty"`
+	State   string        `json:"state"`
+	Elapsed time.Duration `json:"elapsed"`
+
+	// session is set on the runs made since the window opened
+	session bool
+}
+
+func (e *historyEntry) String() string {
+	s := e.Settings
+	mark := " "
+	if e.session {
+		mark = "*"
+	}
+	return fmt.Sprintf("%s#%d %s %sx%s %s blur %s chan %s, %s in %s", mark, e.ID, e.Time.Format("Jan 2 15:04"),
+		s["width"], s["height"], s["colour-basis"], s["blur"], s["chan"], e.State, e.Elapsed.Round(time.Second))
+}
+
+// runHistory is the window's runs, this session's and the ones before,
+// kept in dir as an index and a thumbnail for each.
+type runHistory struct {
+	dir     string
+	entries []*historyEntry
+	// onChange is called when a run's added, to refresh what shows them
+	onChange func()
+}
+
+const (
+	historyLimit     = 200
+	historyThumbSize = 128
+)
+
+func historyDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pixelart", "history")
+}
+
+// loadHistory reads the history kept in dir. A missing one is empty.
+func loadHistory(dir string) (*runHistory, error) {
+	h := &runHistory{dir: dir}
+	if dir == "" {
+		return h, errors.New("no config directory to keep the run history in")
+	}
+	data, err := os.ReadFile(h.index())
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	} else if err != nil {
+		return h, err
+	}
+	if err = json.Unmarshal(data, &h.entries); err != nil {
+		return h, fmt.Errorf("%s: %v", h.index(), err)
+	}
+	return h, nil
+}
+
+func (h *runHistory) index() string {
+	return filepath.Join(h.dir, "history.json")
+}
+
+func (h *runHistory) thumbPath(e *historyEntry) string {
+	return filepath.Join(h.dir, strconv.Itoa(e.ID)+".png")
+}
+
+// add records a run with the last picture it made, dropping the oldest
+// runs past historyLimit.
+func (h *runHistory) add(e *historyEntry, pic image.Image) error {
+	e.ID = 1
+	if n := len(h.entries); n > 0 {
+		e.ID = h.entries[n-1].ID + 1
+	}
+	e.session = true
+	h.entries = append(h.entries, e)
+	for len(h.entries) > historyLimit {
+		os.Remove(h.thumbPath(h.entries[0]))
+		h.entries = h.entries[1:]
+	}
+	if h.onChange != nil {
+		h.onChange()
+	}
+
+	if h.dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(h.dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(h.thumbPath(e))
+	if err != nil {
+		return err
+	}
+	if err = png.Encode(file, pixelart.Thumbnail(pic, historyThumbSize)); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(h.entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	// written whole then renamed, so a crash can't leave half an index
+	tmp := h.index() + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.index())
+}
+
+// newest lists the entries, latest first.
+func (h *runHistory) newest() []*historyEntry {
+	list := make([]*historyEntry, len(h.entries))
+	for i, e := range h.entries {
+		list[len(list)-1-i] = e
+	}
+	return list
+}
+
+// picture loads the run's image if it's still there, or else its
+// thumbnail. full says which it was.
+func (h *runHistory) picture(e *historyEntry) (pic image.Image, full bool, err error) {
+	if e.Output != "" {
+		if pic, err = loadImage(e.Output); err == nil {
+			return pic, true, nil
+		}
+	}
+	pic, err = loadImage(h.thumbPath(e))
+	return pic, false, err
+}
+
+// showHistory opens a window listing the runs. A click picks one to look
+// at or restore, shift-click a second to compare it with.
+func showHistory(theme gxui.Theme, data map[string]*dataField) {
+	window := theme.CreateWindow(600, 560, "Run history")
+	layout := theme.CreateLinearLayout()
+	layout.SetDirection(gxui.TopToBottom)
+	window.AddChild(layout)
+
+	var picked, other *historyEntry
+
+	adapter := gxui.CreateDefaultAdapter()
+	adapter.SetSize(math.Size{W: 580, H: 18})
+	adapter.SetItems(History.newest())
+	History.onChange = func() { adapter.SetItems(History.newest()) }
+	window.OnClose(func() { History.onChange = nil })
+
+	hint := theme.CreateLabel()
+	hint.SetText("Click a run to pick it, shift-click another to compare them. * is this session's.")
+	layout.AddChild(hint)
+
+	list := theme.CreateList()
+	list.SetAdapter(adapter)
+	layout.AddChild(list)
+
+	row := theme.CreateLinearLayout()
+	row.SetDirection(gxui.LeftToRight)
+	thumb := theme.CreateImage()
+	thumb.SetExplicitSize(math.Size{W: historyThumbSize, H: historyThumbSize})
+	thumb.SetAspectMode(gxui.AspectCorrectLetterbox)
+	row.AddChild(thumb)
+	details := theme.CreateLabel()
+	details.SetMultiline(true)
+	row.AddChild(details)
+	layout.AddChild(row)
+
+	problem := theme.CreateLabel()
+	problem.SetColor(gxui.Red)
+
+	show := func() {
+		problem.SetText("")
+		if picked == nil {
+			return
+		}
+		text := picked.String()
+		if picked.Output != "" {
+			text += "\n" + picked.Output
+		}
+		if other != nil {
+			text += fmt.Sprintf("\ncompare with #%d", other.ID)
+		}
+		details.SetText(text)
+		pic, err := loadImage(History.thumbPath(picked))
+		if err != nil {
+			thumb.SetTexture(nil)
+			problem.SetText(err.Error())
+			return
+		}
+		thumb.SetTexture(Driver.CreateTexture(pic, 1))
+	}
+	list.OnItemClicked(func(ev gxui.MouseEvent, item gxui.AdapterItem) {
+		e := item.(*historyEntry)
+		if ev.Modifier.Shift() && picked != nil && e != picked {
+			other = e
+		} else {
+			picked, other = e, nil
+		}
+		show()
+	})
+
+	buttons := theme.CreateLinearLayout()
+	buttons.SetDirection(gxui.LeftToRight)
+	restore := theme.CreateButton()
+	restore.SetText("Restore")
+	restore.OnClick(func(gxui.MouseEvent) {
+		if picked == nil {
+			problem.SetText("Pick a run to restore")
+			return
+		}
+		if err := putSettings(picked.Settings, data, true); err != nil {
+			problem.SetText(err.Error())
+			return
+		}
+		Status.SetText(fmt.Sprintf("Restored run #%d", picked.ID))
+	})
+	buttons.AddChild(restore)
+	compare := theme.CreateButton()
+	compare.SetText("Compare")
+	compare.OnClick(func(gxui.MouseEvent) {
+		if picked == nil || other == nil {
+			problem.SetText("Pick a run, then shift-click another to compare it with")
+			return
+		}
+		if err := compareRuns(theme, picked, other); err != nil {
+			problem.SetText(err.Error())
+		}
+	})
+	buttons.AddChild(compare)
+	layout.AddChild(buttons)
+	layout.AddChild(problem)
+}
+
+// recordRun adds a finished or stopped run to the history, once both its
+// end and its final picture have come in; they arrive in either order.
+func (r *guiRun) recordRun() {
+	if !r.ended || r.recorded {
+		return
+	}
+	e := &historyEntry{
+		Time:     r.started,
+		Settings: pixelart.ArgsSettings(r.args),
+		Elapsed:  r.elapsed,
+	}
+	pic := r.final
+	switch {
+	case r.err == nil:
+		if pic == nil {
+			return
+		}
+		e.State = "done"
+		e.Output, _ = filepath.Abs(r.args.Name)
+	case errors.Is(r.err, context.Canceled):
+		snap, _ := r.preview.Snapshot()
+		if snap == nil {
+			return
+		}
+		pic = snap
+		e.State = "stopped"
+	default:
+		return
+	}
+	r.recorded = true
+	if err := History.add(e, pic); err != nil {
+		Status.SetText("Cannot save the run history: " + err.Error())
+	}
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Kapura/pixelart"
+)
+
+// parseFlags reads the flags of a run into fs. The -gui flag is there for
+// the command line from before there were subcommands, and defaults to
+// guiDefault.
+func parseFlags(fs *flag.FlagSet, argv []string, guiDefault bool) (args pixelart.GenerateArgs, save saveOptions, gui bool){
+
+	var(
+		seedImagePath     string
+		seedRejectionRate float64
+		seedChroma        int
+		seedDupes         bool
+		palettePath       string
+
+		echospacing float64
+
+		seedColour int
+		p_red      int
+		p_green    int
+		p_blue     int
+
+		colourAxes  string
+
+		x int
+		y int
+		seeds string
+
+		width  int
+		height int
+
+		blur         int
+		ch_cap       int
+		cpu_cap      int
+
+		draw_intermediate bool
+		flip_draw         bool
+
+		tag  string
+		name string
+
+		format         string
+		pngCompression string
+
+		anim animFlags
+		term termFlags
+		snap snapFlags
+
+		fillOrderPath string
+		fillOrderBits int
+
+		statsPath string
+
+		streamPath   string
+		streamFormat string
+		streamEvery  int
+		streamFPS    int
+		streamScale  float64
+
+		rngSeed            int64
+		checkpointPath     string
+		checkpointInterval time.Duration
+		resumePath         string
+
+		configPath string
+		preset     string
+		saveConfig string
+
+	)
+
+	fs.BoolVar(&gui, "gui", guiDefault, "Use the GUI. Set to 'false' for CLI arguments")
+
+	fs.IntVar(&p_red, "seed-red", 0, "red value of the initial point")
+	fs.IntVar(&p_green, "seed-green", 0, "green value of the initial point")
+	fs.IntVar(&p_blue, "seed-blue", 0, "blue value of the initial point")
+
+	fs.StringVar(&colourAxes, "colour-basis", "rgb", "colour axes to use: one of [rgb, rbg, gbr, grb, bgr, brg]")
+
+	fs.IntVar(&width, "width", 4096, "Output image width (if not using seed image)")
+	fs.IntVar(&height, "height", 4096, "Output image height (if not using seed image")
+
+	fs.IntVar(&seedColour, "seed", 0x0, "seed colour (e.g. 0xFFFFFF)")
+	fs.IntVar(&x, "seed-x", 0, "x position of the initial point")
+	fs.IntVar(&y, "seed-y", 0, "y position of the initial point")
+	fs.StringVar(&seeds, "seeds", "", "more seed points, as space separated x,y,0xRRGGBB")
+	fs.StringVar(&seedImagePath, "seed-image", "", "Pre-seeded image to fill. Empty pixels are 0x000000")
+	fs.StringVar(&palettePath, "palette", "", "image whose colours are the only ones the fill may use")
+	fs.Float64Var(&seedRejectionRate, "seed-rr", 0, "Random rejection rate of seeded pixels between 0 and 1; 0 keeps them all")
+	fs.IntVar(&seedChroma, "seed-chroma-key", 0xFF00FF, "Colour to treat as empty in seeded image")
+	fs.BoolVar(&seedDupes, "seed-dupes", false, "Search for repeated colours in input image. Takes a bit.")
+
+	fs.IntVar(&blur, "blur", 1, "higher values increase time required to complete image.")
+
+	fs.IntVar(&ch_cap, "chan", 8, "very high values produce geometric patterns originating about the initial point.")
+
+	fs.StringVar(&name, "name", "", "name to use for final image file")
+	fs.StringVar(&tag, "tag", "art", "tags for intermediate representation and final file (if no PicName specified)")
+	fs.StringVar(&format, "format", "", "output format: one of ["+strings.Join(pixelart.FormatNames(), ", ")+"]. Defaults to the extension of -name, or png")
+	fs.StringVar(&pngCompression, "png-compression", "default", "PNG compression level: one of [default, none, fast, best]")
+
+	anim.register(fs)
+	term.register(fs)
+	snap.register(fs)
+
+	fs.StringVar(&fillOrderPath, "fill-order", "", "record the order pixels were filled in to this file, as an index image if it has an image extension")
+	fs.IntVar(&fillOrderBits, "fill-order-bits", 24, "bits per pixel of a fill order image: 24 or 32")
+	fs.StringVar(&statsPath, "stats", "", "write a JSON report of the image's and the fill's stats to this file. '-' for stdout")
+
+	fs.StringVar(&streamPath, "stream", "", "stream frames of the fill to this file or named pipe. '-' for stdout")
+	fs.StringVar(&streamFormat, "stream-format", "y4m", "frame stream format: one of [y4m, rgb]. rgb frames follow a 'RGB24 <width> <height> <fps>' line")
+	fs.IntVar(&streamEvery, "stream-every", 4096, "pixels filled between streamed frames")
+	fs.IntVar(&streamFPS, "stream-fps", 60, "frame rate written in the stream header")
+	fs.Float64Var(&streamScale, "stream-scale", 1, "scale of the streamed frames relative to the image")
+
+	fs.BoolVar(&draw_intermediate, "ir", false, "draw intermediate representations of the image, a snapshot every tenth of the run unless -snapshots or the like say otherwise")
+	fs.BoolVar(&flip_draw, "flip-draw", false, "flip ALL colours at the bit level after running")
+
+	fs.Float64Var(&echospacing, "es", 0, "Turn on echospacing/reseeding")
+
+	fs.IntVar(&cpu_cap, "cpus", -1, "amount of cpu's used. 0 means default go runtime settings, <0 means 'use all' (default)")
+
+	fs.Int64Var(&rngSeed, "rng-seed", 0, "seed for a deterministic run. 0 leaves the fill order to the go scheduler")
+	fs.StringVar(&checkpointPath, "checkpoint", "", "file to periodically save progress to. Implies a deterministic run")
+	fs.DurationVar(&checkpointInterval, "checkpoint-interval", 10*time.Minute, "time between checkpoints")
+	fs.StringVar(&resumePath, "resume", "", "checkpoint file to continue a run from. Image settings are taken from the checkpoint")
+
+	fs.StringVar(&configPath, "config", "", "run file (.json, .toml or .yaml) of flag names to values. Flags given on the command line win")
+	fs.StringVar(&preset, "preset", "", "named look to start from: one of ["+strings.Join(pixelart.PresetNames(), ", ")+"] or a run file in "+presetDir())
+	fs.StringVar(&saveConfig, "save-config", "", "write the run's settings to this run file instead of running")
+
+	fs.Parse(argv)
+
+	if err := applySettings(fs, preset, configPath); err != nil {
+		printProblems(err)
+		os.Exit(2)
+	}
+
+	save = defaultSaveOptions()
+
+	if gui {
+		// gui enabled, ignore other args
+		return
+	}
+
+	var err error
+	if format != "" {
+		save.format, err = pixelart.LookupFormat(format)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	} else if f, ok := pixelart.FormatForFile(name); ok {
+		save.format = f
+	}
+	save.encode.PNGCompression, err = pixelart.ParsePNGCompression(pngCompression)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	if err = anim.apply(&save); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	term.apply(&save)
+	snap.apply(&save)
+	save.fillOrderPath = fillOrderPath
+	save.fillOrderBits = fillOrderBits
+	save.statsPath = statsPath
+
+	if streamPath != "" {
+		if streamPath == "-" {
+			// keep the stream on stdout to itself
+			if statsPath == "-" {
+				fmt.Println("-stats and -stream can't both go to stdout")
+				os.Exit(2)
+			}
+			save.log = os.Stderr
+		}
+		save.streamPath = streamPath
+		save.streamEvery = streamEvery
+		save.stream = pixelart.StreamOptions{Format: streamFormat, FPS: streamFPS, Scale: streamScale}
+	}
+
+	if seedColour != 0x0 {
+		p_red = seedColour >> 16
+		p_green = (seedColour & 0x00FF00) >> 8
+		p_blue = seedColour & 0x0000FF
+	}
+
+
+	var problems pixelart.ValidationErrors
+	if seedImagePath != "" {
+		// the seed image sets the size
+		if args.SeedImage, err = loadImage(seedImagePath); err != nil {
+			problems = append(problems, &pixelart.FieldError{Field: "seed-image", Message: err.Error()})
+		} else {
+			width = args.SeedImage.Bounds().Max.X
+			height = args.SeedImage.Bounds().Max.Y
+		}
+	}
+
+	if palettePath != "" {
+		if args.Palette, err = loadImage(palettePath); err != nil {
+			problems = append(problems, &pixelart.FieldError{Field: "palette", Message: err.Error()})
+		}
+	}
+
+	if args.ColourBasis, err = pixelart.ParseColourBasis(strings.ToLower(colourAxes)); err != nil {
+		problems = append(problems, &pixelart.FieldError{Field: "colour-basis", Message: err.Error()})
+	}
+
+	args.ChanSize = int32(ch_cap)
+	args.CPUs = cpu_cap
+	args.Blur = int32(blur)
+	args.Echospace = echospacing
+	args.FlipDraw = flip_draw
+	args.DrawIR = draw_intermediate
+
+	args.Name = name
+	args.Tag = tag
+
+	args.SeedImagePath = seedImagePath
+	args.PalettePath = palettePath
+	args.SeedRejectionRate = seedRejectionRate
+	args.ReseedDupes = seedDupes
+	args.ChromaColour = seedChroma
+
+	args.StartRed = p_red
+	args.StartGreen = p_green
+	args.StartBlue = p_blue
+	args.StartX = x
+	args.StartY = y
+	if args.Seeds, err = pixelart.ParseSeeds(seeds); err != nil {
+		problems = append(problems, &pixelart.FieldError{Field: "seeds", Message: err.Error()})
+	}
+
+	args.Height = height
+	args.Width = width
+
+	args.Update = nil
+	args.UpdateFreq = 10
+
+	args.RNGSeed = rngSeed
+	args.CheckpointPath = checkpointPath
+	args.CheckpointInterval = checkpointInterval
+	args.ResumePath = resumePath
+	if resumePath != "" && checkpointPath == "" {
+		// keep checkpointing where we left off
+		args.CheckpointPath = resumePath
+	}
+
+	// a resumed run's image settings come from the checkpoint
+	if resumePath == "" {
+		problems = append(problems, pixelart.AsValidationErrors(args.Validate())...)
+	}
+	if len(problems) > 0 {
+		printProblems(problems)
+		os.Exit(2)
+	}
+
+	if saveConfig != "" {
+		if err = saveSettings(args, saveConfig); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	return
+
+}
+
+// printProblems prints an error with settings one setting to a line, by
+// the flag that sets it.
+func printProblems(err error) {
+	problems := pixelart.AsValidationErrors(err)
+	if problems == nil {
+		fmt.Println(err)
+		return
+	}
+	for _, p := range problems {
+		fmt.Printf("-%s: %s\n", p.Field, p.Message)
+	}
+}
+
+func CLImain(args pixelart.GenerateArgs, save saveOptions) {
+
+	var time_format = "15:04:05"
+	var start_time = time.Now()
+	save.logf("Start time: %s", start_time.Format(time_format))
+
+	args.Progress = func(p pixelart.Progress) {
+		save.logf("[%s] %s", time.Now().Format(time_format), p)
+	}
+	args.Logf = save.logf
+	if save.preview.frames > 0 {
+		// the preview's bar stands in for the progress lines
+		preview := newTermPreview(save.log, save.preview, args.Width, args.Height)
+		args.Frames = append(args.Frames, preview.hook(save.preview.frames))
+		args.Progress = preview.setProgress
+		args.Logf = preview.logf
+	}
+
+	if err := run(context.Background(), args, save); err != nil {
+		save.logf("%v", err)
+		os.Exit(1)
+	}
+
+	var end_time = time.Now()
+	save.logf("End time: %s", end_time.Format(time_format))
+	save.logf("Image drawn in %s", end_time.Sub(start_time).String())
+
+}
+
+// command is one of the things pixelart can be asked to do.
+type command struct {
+	name    string
+	summary string
+	main    func(argv []string)
+}
+
+var commands = []command{
+	{"generate", "fill an image with every colour at most once", generateMain},
+	{"verify", "check that an image uses each colour exactly once", verifyMain},
+	{"stats", "measure how smooth an image is and how its colours fall", statsMain},
+	{"replay", "draw or re-colour a recorded fill order", replayMain},
+	{"reproduce", "generate an image again from its metadata", reproduceMain},
+	{"sweep", "generate every combination of some settings onto a contact sheet", sweepMain},
+	{"serve", "run generations submitted over HTTP", serveMain},
+	{"gui", "open the window", guiMain},
+}
+
+func usage() {
+	w := os.Stderr
+	fmt.Fprintln(w, "usage: pixelart <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'pixelart <command> -h' for the flags of a command. With no command")
+	fmt.Fprintln(w, "the window opens, and flags without a command are taken as they were")
+	fmt.Fprintln(w, "before there were commands, -gui and all.")
+}
+
+func generateMain(argv []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pixelart generate [flags]")
+		fs.PrintDefaults()
+	}
+	args, save, gui := parseFlags(fs, argv, false)
+	if gui {
+		GUImain()
+	} else {
+		CLImain(args, save)
+	}
+}
+
+func guiMain(argv []string) {
+	fs := flag.NewFlagSet("gui", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pixelart gui")
+	}
+	fs.Parse(argv)
+	GUImain()
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		GUImain()
+		return
+	}
+
+	name := os.Args[1]
+	if strings.HasPrefix(name, "-") && name != "-h" && name != "-help" && name != "--help" {
+		// the old command line
+		args, save, gui := parseFlags(flag.CommandLine, os.Args[1:], true)
+		if gui {
+			GUImain()
+		} else {
+			CLImain(args, save)
+		}
+		return
+	}
+
+	if name == "help" && len(os.Args) > 2 {
+		name = os.Args[2]
+		os.Args = []string{os.Args[0], name, "-h"}
+	}
+	for _, c := range commands {
+		if c.name == name {
+			c.main(os.Args[2:])
+			return
+		}
+	}
+	usage()
+	if name != "help" && name != "-h" && name != "-help" && name != "--help" {
+		os.Exit(2)
+	}
+}
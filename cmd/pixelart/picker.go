@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/gxui"
+	"github.com/google/gxui/math"
+)
+
+// imageExtensions are the files loadImage can open.
+var imageExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".bmp": true, ".tif": true, ".tiff": true,
+}
+
+// fileBrowser lists a directory's subdirectories and images, going into
+// the directories that are clicked and handing the images to onFile. gxui
+// has no file dialogs of its own.
+type fileBrowser struct {
+	dir     string
+	where   gxui.Label
+	adapter *gxui.DefaultAdapter
+	problem gxui.Label
+}
+
+func newFileBrowser(theme gxui.Theme, layout gxui.LinearLayout, dir string, onFile func(path string)) *fileBrowser {
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	b := &fileBrowser{where: theme.CreateLabel(), adapter: gxui.CreateDefaultAdapter(), problem: theme.CreateLabel()}
+	b.adapter.SetSize(math.Size{W: 380, H: 18})
+	b.problem.SetColor(gxui.Red)
+
+	list := theme.CreateList()
+	list.SetAdapter(b.adapter)
+	list.OnItemClicked(func(_ gxui.MouseEvent, item gxui.AdapterItem) {
+		name := item.(string)
+		if strings.HasSuffix(name, string(filepath.Separator)) {
+			b.show(filepath.Clean(filepath.Join(b.dir, name)))
+			return
+		}
+		onFile(filepath.Join(b.dir, name))
+	})
+
+	layout.AddChild(b.where)
+	layout.AddChild(list)
+	layout.AddChild(b.problem)
+	b.show(dir)
+	return b
+}
+
+func (b *fileBrowser) show(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		b.problem.SetText(err.Error())
+		return
+	}
+	b.problem.SetText("")
+	b.dir = dir
+	b.where.SetText(dir)
+	// directories first, each ending in a slash, then the images
+	items := []string{".." + string(filepath.Separator)}
+	var images []string
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if e.IsDir() {
+			items = append(items, name+string(filepath.Separator))
+		} else if imageExtensions[strings.ToLower(filepath.Ext(name))] {
+			images = append(images, name)
+		}
+	}
+	sort.Strings(items[1:])
+	sort.Strings(images)
+	b.adapter.SetItems(append(items, images...))
+}
+
+// pickImage opens a window to browse for an image from dir, calling onPick
+// with the one clicked.
+func pickImage(theme gxui.Theme, dir string, onPick func(path string)) {
+	window := theme.CreateWindow(400, 500, "Choose an image")
+	layout := theme.CreateLinearLayout()
+	layout.SetDirection(gxui.TopToBottom)
+	window.AddChild(layout)
+
+	newFileBrowser(theme, layout, dir, func(path string) {
+		window.Close()
+		onPick(path)
+	})
+}
+
+// saveImageAs opens a window to choose where to save an image, starting
+// at path, and calls onSave with the file chosen. onSave's error is shown
+// and the window kept open.
+func saveImageAs(theme gxui.Theme, path string, onSave func(path string) error) {
+	window := theme.CreateWindow(400, 540, "Save image as")
+	layout := theme.CreateLinearLayout()
+	layout.SetDirection(gxui.TopToBottom)
+	window.AddChild(layout)
+
+	name := theme.CreateTextBox()
+	name.SetDesiredWidth(300)
+	name.SetText(filepath.Base(path))
+	// clicking an image puts its name in the box, to save over it
+	browser := newFileBrowser(theme, layout, filepath.Dir(path), func(picked string) {
+		name.SetText(filepath.Base(picked))
+	})
+
+	row := theme.CreateLinearLayout()
+	row.SetDirection(gxui.LeftToRight)
+	label := theme.CreateLabel()
+	label.SetText("name")
+	row.AddChild(label)
+	row.AddChild(name)
+	save := theme.CreateButton()
+	save.SetText("Save")
+	save.OnClick(func(gxui.MouseEvent) {
+		file := strings.TrimSpace(name.Text())
+		if file == "" {
+			browser.problem.SetText("Give the image a name")
+			return
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(browser.dir, file)
+		}
+		if err := onSave(file); err != nil {
+			browser.problem.SetText(err.Error())
+			return
+		}
+		window.Close()
+	})
+	row.AddChild(save)
+	layout.AddChild(row)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	stddraw "image/draw"
+	stdmath "math"
+	"strconv"
+	"strings"
+
+	"github.com/Kapura/pixelart"
+	"github.com/google/gxui"
+	"github.com/google/gxui/math"
+)
+
+var (
+	// ExtraSeeds are the seeds placed on the preview besides the start
+	// point, and PickedColour the colour the next seed placed gets.
+	ExtraSeeds   []pixelart.SeedPixel
+	PickedColour = color.RGBA{0, 0, 0, 0xFF}
+	// SeedPic is the seed image, if one's chosen, shown under the markers.
+	SeedPic image.Image
+
+	PickerPic    gxui.Image
+	PickerPixels *image.RGBA
+	PickedSwatch gxui.Image
+	SeedsLabel   gxui.Label
+)
+
+// createColourPicker lays out a strip of colours to click on, hue across
+// and from white through the full colour to black down, with a swatch of
+// the colour picked.
+func createColourPicker(theme gxui.Theme) gxui.Control {
+	const w, h = 192, 48
+	PickerPixels = image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		hue := float64(x) * 360 / w
+		for y := 0; y < h; y++ {
+			s, v := 1.0, 1.0
+			if y < h/2 {
+				s = float64(y) / float64(h/2)
+			} else {
+				v = 1 - float64(y-h/2)/float64(h/2)
+			}
+			PickerPixels.SetRGBA(x, y, hsv(hue, s, v))
+		}
+	}
+
+	layout := theme.CreateLinearLayout()
+	layout.SetDirection(gxui.LeftToRight)
+
+	PickerPic = theme.CreateImage()
+	PickerPic.SetExplicitSize(math.Size{W: w, H: h})
+	PickerPic.SetTexture(theme.Driver().CreateTexture(PickerPixels, 1))
+	PickerPic.OnClick(func(ev gxui.MouseEvent) {
+		if p, ok := PickerPic.PixelAt(ev.Point); ok && image.Pt(p.X, p.Y).In(PickerPixels.Bounds()) {
+			setPickedColour(PickerPixels.RGBAAt(p.X, p.Y))
+		}
+	})
+	layout.AddChild(PickerPic)
+
+	PickedSwatch = theme.CreateImage()
+	PickedSwatch.SetExplicitSize(math.Size{W: 24, H: 24})
+	PickedSwatch.SetBorderPen(gxui.CreatePen(1, gxui.Color{0, 0, 0, 1}))
+	layout.AddChild(PickedSwatch)
+	setPickedColour(PickedColour)
+	return layout
+}
+
+func setPickedColour(c color.RGBA) {
+	PickedColour = c
+	PickedSwatch.SetBackgroundBrush(gxui.CreateBrush(gxui.Color{
+		R: float32(c.R) / 255,
+		G: float32(c.G) / 255,
+		B: float32(c.B) / 255,
+		A: 1,
+	}))
+}
+
+// hsv turns a hue in degrees, saturation and value into RGB.
+func hsv(h, s, v float64) color.RGBA {
+	c := v * s
+	x := c * (1 - stdmath.Abs(stdmath.Mod(h/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g = c, x
+	case h < 120:
+		r, g = x, c
+	case h < 180:
+		g, b = c, x
+	case h < 240:
+		g, b = x, c
+	case h < 300:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := v - c
+	return color.RGBA{uint8((r+m)*255 + 0.5), uint8((g+m)*255 + 0.5), uint8((b+m)*255 + 0.5), 0xFF}
+}
+
+// onPreviewClick places seeds where the preview is clicked: a click moves
+// the start point, shift-click adds a seed and ctrl-click takes away the
+// nearest one. Each seed placed gets the picked colour.
+func onPreviewClick(ev gxui.MouseEvent, data map[string]*dataField) {
+	if Running != nil || PreviewView.zoom == 0 {
+		return
+	}
+	p, ok := ProgPic.PixelAt(ev.Point)
+	if !ok {
+		return
+	}
+	w, h, ok := canvasSize(data)
+	if !ok {
+		return
+	}
+	pt := PreviewView.toImage(image.Pt(p.X, p.Y), previewSize)
+	pt.X, pt.Y = minint(maxint(pt.X, 0), w-1), minint(maxint(pt.Y, 0), h-1)
+	c := PickedColour
+
+	switch {
+	case ev.Modifier.Control():
+		// a few screen pixels' slack, whatever the scale
+		reach := 6 / PreviewView.zoom
+		best := -1
+		for i, sp := range ExtraSeeds {
+			d := stdmath.Hypot(float64(sp.Pt.X-pt.X), float64(sp.Pt.Y-pt.Y))
+			if d <= reach {
+				best, reach = i, d
+			}
+		}
+		if best >= 0 {
+			ExtraSeeds = append(ExtraSeeds[:best], ExtraSeeds[best+1:]...)
+		}
+	case ev.Modifier.Shift():
+		ExtraSeeds = append(ExtraSeeds, pixelart.NewSeedPixel(c.R, c.G, c.B, pt.X, pt.Y))
+	default:
+		data["start X"].Put(strconv.Itoa(pt.X))
+		data["start Y"].Put(strconv.Itoa(pt.Y))
+		data["seed colour"].Put(fmt.Sprintf("0x%02X%02X%02X", c.R, c.G, c.B))
+	}
+	showPlacement(data)
+}
+
+// canvasSize reads the size fields, if they hold a size that can be made.
+func canvasSize(data map[string]*dataField) (w, h int, ok bool) {
+	w, err1 := strconv.Atoi(strings.TrimSpace(data["width"].Get()))
+	h, err2 := strconv.Atoi(strings.TrimSpace(data["height"].Get()))
+	ok = err1 == nil && err2 == nil && w >= 1 && h >= 1 && w <= pixelart.MaxWidth && h <= pixelart.MaxHeight
+	return
+}
+
+func onClearSeeds(data map[string]*dataField) {
+	ExtraSeeds = nil
+	showPlacement(data)
+}
+
+// showPlacement draws the empty canvas, or the seed image, in the preview
+// with a marker on each seed, while there's no run to show. Fields that
+// don't parse leave it as it was.
+func showPlacement(data map[string]*dataField) {
+	SeedsLabel.SetText(fmt.Sprintf("%d more seeds. Click to move the start, shift-click to add a seed, ctrl-click to remove one", len(ExtraSeeds)))
+	if Running != nil {
+		return
+	}
+	w, h, ok := canvasSize(data)
+	if !ok {
+		return
+	}
+	showCanvas(image.Pt(w, h))
+	PreviewPic, PreviewBuf = nil, nil
+	var canvas *image.RGBA
+	if SeedPic != nil {
+		canvas = renderView(SeedPic, PreviewView, previewSize)
+	} else {
+		canvas = renderView(blankCanvas{w, h}, PreviewView, previewSize)
+	}
+
+	marker := func(x, y int, c color.RGBA) {
+		// in the middle of the seed's pixel, however far in it's zoomed
+		at := PreviewView.toScreen(image.Pt(x, y), previewSize).Add(image.Pt(int(PreviewView.zoom/2), int(PreviewView.zoom/2)))
+		// a ring that shows up on dark and light, around the seed's colour
+		stddraw.Draw(canvas, image.Rect(at.X-5, at.Y-5, at.X+6, at.Y+6), image.White, image.Point{}, stddraw.Src)
+		stddraw.Draw(canvas, image.Rect(at.X-4, at.Y-4, at.X+5, at.Y+5), image.Black, image.Point{}, stddraw.Src)
+		stddraw.Draw(canvas, image.Rect(at.X-3, at.Y-3, at.X+4, at.Y+4), image.NewUniform(c), image.Point{}, stddraw.Src)
+	}
+	if SeedPic == nil {
+		sx, err1 := strconv.Atoi(strings.TrimSpace(data["start X"].Get()))
+		sy, err2 := strconv.Atoi(strings.TrimSpace(data["start Y"].Get()))
+		sc, err3 := strconv.ParseInt(strings.TrimSpace(data["seed colour"].Get()), 0, 0)
+		if err1 == nil && err2 == nil && err3 == nil {
+			marker(sx, sy, color.RGBA{uint8(sc >> 16), uint8(sc >> 8), uint8(sc), 0xFF})
+		}
+	}
+	for _, sp := range ExtraSeeds {
+		marker(sp.Pt.X, sp.Pt.Y, color.RGBA{uint8(sp.Red()), uint8(sp.Green()), uint8(sp.Blue()), 0xFF})
+	}
+
+	ProgPic.SetTexture(Driver.CreateTexture(canvas, 1))
+	PreviewRedraw = func() { showPlacement(data) }
+}
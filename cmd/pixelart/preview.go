@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	stdmath "math"
+
+	"github.com/Kapura/pixelart"
+	"github.com/google/gxui"
+	"github.com/google/gxui/math"
+)
+
+var (
+	// PreviewView is the part of the canvas in the preview, and
+	// PreviewCanvas the size of the canvas it's a view of.
+	PreviewView   pictureView
+	PreviewCanvas image.Point
+	// PreviewRedraw draws the preview again after the view's changed, with
+	// whatever was last shown in it. PreviewPic is the picture of a run it's
+	// showing, or PreviewBuf the buffer of one still going, if it isn't
+	// showing the seeds being placed.
+	PreviewRedraw func()
+	PreviewPic    image.Image
+	PreviewBuf    *pixelart.PreviewBuffer
+	// Inspect shows the pixel under the mouse.
+	Inspect gxui.Label
+)
+
+// the side of the square the preview is shown in
+const previewSize = 512
+
+// pictureView is what part of a picture is shown: the image pixel in the
+// middle, and how many screen pixels a picture pixel takes.
+type pictureView struct {
+	centre image.Point
+	zoom   float64
+}
+
+// fitView shows all of a canvas of size in a square of side pixels.
+func fitView(size image.Point, side int) pictureView {
+	return pictureView{
+		centre: image.Pt(size.X/2, size.Y/2),
+		zoom:   float64(side) / float64(maxint(1, maxint(size.X, size.Y))),
+	}
+}
+
+// toImage is the picture pixel at p in a square of side pixels, and toScreen
+// the first screen pixel that shows picture pixel p.
+func (v pictureView) toImage(p image.Point, side int) image.Point {
+	return image.Pt(
+		v.centre.X+int(stdmath.Floor(float64(p.X-side/2)/v.zoom)),
+		v.centre.Y+int(stdmath.Floor(float64(p.Y-side/2)/v.zoom)))
+}
+
+func (v pictureView) toScreen(p image.Point, side int) image.Point {
+	return image.Pt(
+		side/2+int(stdmath.Ceil(float64(p.X-v.centre.X)*v.zoom)),
+		side/2+int(stdmath.Ceil(float64(p.Y-v.centre.Y)*v.zoom)))
+}
+
+// renderView draws pic as seen through v into a square of size pixels,
+// with nearest neighbour sampling so zoomed pixels stay sharp. What's off
+// the picture is grey.
+func renderView(pic image.Image, v pictureView, size int) *image.RGBA {
+	out := image.NewRGBA(image.Rect(0, 0, size, size))
+	b := pic.Bounds()
+	grey := color.RGBA{0x80, 0x80, 0x80, 0xFF}
+	// the canvas comes as RGBA, and At is slow enough to notice
+	rgba, _ := pic.(*image.RGBA)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			p := v.toImage(image.Pt(x, y), size)
+			switch {
+			case !p.In(b):
+				out.SetRGBA(x, y, grey)
+			case rgba != nil:
+				out.SetRGBA(x, y, rgba.RGBAAt(p.X, p.Y))
+			default:
+				out.Set(x, y, pic.At(p.X, p.Y))
+			}
+		}
+	}
+	return out
+}
+
+// blankCanvas is an empty canvas to place seeds on, without making one
+// that may be 4096 pixels square.
+type blankCanvas image.Point
+
+func (c blankCanvas) ColorModel() color.Model { return color.RGBAModel }
+func (c blankCanvas) Bounds() image.Rectangle { return image.Rect(0, 0, c.X, c.Y) }
+func (c blankCanvas) At(x, y int) color.Color { return color.Black }
+
+// zoomButtons lays out the buttons to zoom a view in and out, to one
+// screen pixel a pixel, and to fit the picture in.
+func zoomButtons(theme gxui.Theme, zoomBy func(f float64), actual, fit func()) gxui.LinearLayout {
+	controls := theme.CreateLinearLayout()
+	controls.SetDirection(gxui.LeftToRight)
+	for _, c := range []struct {
+		text string
+		do   func()
+	}{
+		{"Zoom in", func() { zoomBy(2) }},
+		{"Zoom out", func() { zoomBy(0.5) }},
+		{"1:1", actual},
+		{"Fit", fit},
+	} {
+		do := c.do
+		button := theme.CreateButton()
+		button.SetText(c.text)
+		button.OnClick(func(gxui.MouseEvent) { do() })
+		controls.AddChild(button)
+	}
+	return controls
+}
+
+// createPreview lays out the preview with its zoom buttons and the pixel
+// inspector. Scrolling zooms around the mouse and dragging pans; clicks
+// place seeds.
+func createPreview(theme gxui.Theme, data map[string]*dataField) gxui.Control {
+	layout := theme.CreateLinearLayout()
+	layout.SetDirection(gxui.TopToBottom)
+
+	ProgPic = theme.CreateImage()
+	ProgPic.SetExplicitSize(math.Size{W: previewSize, H: previewSize})
+	layout.AddChild(ProgPic)
+
+	// a drag pans rather than places a seed when it's let go
+	var dragFrom image.Point
+	var down, dragged bool
+	ProgPic.OnMouseDown(func(ev gxui.MouseEvent) {
+		if p, ok := ProgPic.PixelAt(ev.Point); ok {
+			dragFrom, down, dragged = image.Pt(p.X, p.Y), true, false
+		}
+	})
+	ProgPic.OnMouseUp(func(gxui.MouseEvent) { down = false })
+	ProgPic.OnMouseExit(func(gxui.MouseEvent) {
+		down = false
+		Inspect.SetText("")
+	})
+	ProgPic.OnMouseMove(func(ev gxui.MouseEvent) {
+		p, ok := ProgPic.PixelAt(ev.Point)
+		if !ok {
+			return
+		}
+		at := image.Pt(p.X, p.Y)
+		if down && (dragged || abs(at.X-dragFrom.X)+abs(at.Y-dragFrom.Y) > 3) {
+			dragged = true
+			from, to := PreviewView.toImage(dragFrom, previewSize), PreviewView.toImage(at, previewSize)
+			if from != to {
+				PreviewView.centre = PreviewView.centre.Add(from.Sub(to))
+				dragFrom = at
+				redrawPreview()
+			}
+		}
+		inspect(PreviewView.toImage(at, previewSize))
+	})
+	ProgPic.OnMouseScroll(func(ev gxui.MouseEvent) {
+		p, ok := ProgPic.PixelAt(ev.Point)
+		if !ok {
+			return
+		}
+		if ev.ScrollY > 0 {
+			zoomPreview(2, image.Pt(p.X, p.Y))
+		} else if ev.ScrollY < 0 {
+			zoomPreview(0.5, image.Pt(p.X, p.Y))
+		}
+	})
+	ProgPic.OnClick(func(ev gxui.MouseEvent) {
+		if !dragged {
+			onPreviewClick(ev, data)
+		}
+	})
+
+	middle := image.Pt(previewSize/2, previewSize/2)
+	layout.AddChild(zoomButtons(theme,
+		func(f float64) { zoomPreview(f, middle) },
+		func() { zoomPreview(1/PreviewView.zoom, middle) },
+		func() {
+			PreviewView = fitView(PreviewCanvas, previewSize)
+			redrawPreview()
+		}))
+
+	Inspect = theme.CreateLabel()
+	layout.AddChild(Inspect)
+	return layout
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// zoomPreview zooms by f, keeping the picture pixel at the screen point
+// around where it is.
+func zoomPreview(f float64, around image.Point) {
+	fit := fitView(PreviewCanvas, previewSize).zoom
+	zoom := stdmath.Max(stdmath.Min(fit, 1)/2, stdmath.Min(64, PreviewView.zoom*f))
+	at := PreviewView.toImage(around, previewSize)
+	PreviewView.zoom = zoom
+	// move the centre so at's back under the mouse
+	PreviewView.centre = PreviewView.centre.Add(at.Sub(PreviewView.toImage(around, previewSize)))
+	redrawPreview()
+}
+
+// showCanvas gets the preview ready for a canvas of size, fitting it in if
+// the size has changed.
+func showCanvas(size image.Point) {
+	if size != PreviewCanvas {
+		PreviewCanvas = size
+		PreviewView = fitView(size, previewSize)
+	}
+}
+
+func redrawPreview() {
+	if PreviewRedraw != nil {
+		PreviewRedraw()
+	}
+}
+
+// showPicture puts a picture of the canvas in the preview.
+func showPicture(pic image.Image) {
+	showCanvas(pic.Bounds().Size())
+	PreviewPic, PreviewBuf = pic, nil
+	PreviewRedraw = func() {
+		ProgPic.SetTexture(Driver.CreateTexture(renderView(pic, PreviewView, previewSize), 1))
+	}
+	PreviewRedraw()
+}
+
+// showBuffer puts the canvas of a run that's still going in the preview.
+// Zoomed out far enough, it's drawn from the mipmap, which is as sharp
+// there and a fraction of the pixels to look at.
+func showBuffer(buf *pixelart.PreviewBuffer) {
+	PreviewPic, PreviewBuf = nil, buf
+	PreviewRedraw = func() {
+		step := buf.Step()
+		buf.Read(func(pic, mip *image.RGBA, _ int) {
+			if pic == nil {
+				return
+			}
+			showCanvas(pic.Bounds().Size())
+			src, v := pic, PreviewView
+			if mip != nil && step > 1 && v.zoom*float64(step) <= 1 {
+				src = mip
+				v = pictureView{v.centre.Div(step), v.zoom * float64(step)}
+			}
+			ProgPic.SetTexture(Driver.CreateTexture(renderView(src, v, previewSize), 1))
+		})
+	}
+	PreviewRedraw()
+}
+
+// inspect shows the pixel at pt of the run in the preview: its colour, and
+// once the run's over, when it was filled and how close it came to the
+// colour it wanted.
+func inspect(pt image.Point) {
+	if !pt.In(image.Rectangle{Max: PreviewCanvas}) {
+		Inspect.SetText("")
+		return
+	}
+	text := fmt.Sprintf("%d,%d", pt.X, pt.Y)
+	var c color.RGBA
+	var ok bool
+	switch {
+	case PreviewPic != nil:
+		if ok = pt.In(PreviewPic.Bounds()); ok {
+			c = color.RGBAModel.Convert(PreviewPic.At(pt.X, pt.Y)).(color.RGBA)
+		}
+	case PreviewBuf != nil:
+		PreviewBuf.Read(func(pic, _ *image.RGBA, _ int) {
+			if ok = pic != nil && pt.In(pic.Bounds()); ok {
+				c = pic.RGBAAt(pt.X, pt.Y)
+			}
+		})
+	}
+	if !ok {
+		Inspect.SetText(text)
+		return
+	}
+	text += fmt.Sprintf("  0x%02X%02X%02X", c.R, c.G, c.B)
+
+	// the fill order's only safe to read once the run's let go of it
+	if order := LastRun.order; PreviewPic == LastPic && LastRun.ended && order != nil && pt.In(image.Rect(0, 0, order.Width, order.Height)) {
+		switch i := order.At(pt.X, pt.Y); {
+		case i == pixelart.NotFilled:
+			text += "  not filled"
+		case int(i) < order.Seeds:
+			text += fmt.Sprintf("  seed %d", i+1)
+		default:
+			text += fmt.Sprintf("  filled %d of %d", int(i)-order.Seeds+1, order.Width*order.Height-order.Seeds)
+		}
+		if d, ok := order.DistanceAt(pt.X, pt.Y); ok {
+			text += fmt.Sprintf("  %.1f from the colour it wanted", d)
+		}
+	}
+	Inspect.SetText(text)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/Kapura/pixelart"
+)
+
+// replayMain plays back a fill order recorded with -fill-order. Given the
+// finished -image it draws the fill as it stood part way through; without
+// one it re-colours the order under new colourspace settings.
+func replayMain(argv []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pixelart replay -order file [-image final.png -at 0.5 | colour flags] [-name out.png]")
+		fs.PrintDefaults()
+	}
+
+	var (
+		orderPath  string
+		orderBits  int
+		orderSeeds int
+		imagePath  string
+		at         float64
+		colourAxes string
+		echospace  float64
+		blur       int
+		seedColour int
+		palette    string
+		flipDraw   bool
+		seedImage  string
+		name       string
+		format     string
+		anim       animFlags
+	)
+	fs.StringVar(&orderPath, "order", "", "fill order recorded with -fill-order")
+	fs.IntVar(&orderBits, "order-bits", 24, "bits per pixel of a fill order image: 24 or 32")
+	fs.IntVar(&orderSeeds, "order-seeds", -1, "number of seeds of a fill order image that doesn't record it, as only PNGs do")
+	fs.StringVar(&imagePath, "image", "", "finished image to draw the fill of. Leave empty to re-colour the order instead")
+	fs.Float64Var(&at, "at", 1, "fraction of the fill to draw from -image, between 0 and 1")
+	fs.StringVar(&colourAxes, "colour-basis", "rgb", "colour axes to re-colour with: one of [rgb, rbg, gbr, grb, bgr, brg]")
+	fs.Float64Var(&echospace, "es", 0, "echospacing to re-colour with")
+	fs.IntVar(&blur, "blur", 1, "blur to re-colour with")
+	fs.IntVar(&seedColour, "seed", 0x0, "colour of the seed pixels when re-colouring (e.g. 0xFFFFFF)")
+	fs.StringVar(&seedImage, "seed-image", "", "image to take the seed pixels' colours from when re-colouring")
+	fs.StringVar(&palette, "palette", "", "image whose colours are the only ones the re-colouring may use")
+	fs.BoolVar(&flipDraw, "flip-draw", false, "flip ALL colours at the bit level after re-colouring")
+	fs.StringVar(&name, "name", "replay.png", "name of the output image")
+	fs.StringVar(&format, "format", "", "output format. Defaults to the extension of -name, or png")
+	anim.register(fs)
+	fs.Parse(argv)
+
+	if err := replay(orderPath, orderBits, orderSeeds, imagePath, at, colourAxes, echospace, blur, seedColour,
+		seedImage, palette, flipDraw, name, format, anim); err != nil {
+		if pixelart.AsValidationErrors(err) != nil {
+			printProblems(err)
+			os.Exit(2)
+		}
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func replay(orderPath string, orderBits, orderSeeds int, imagePath string, at float64, colourAxes string,
+	echospace float64, blur, seedColour int, seedImage, palette string, flipDraw bool,
+	name, format string, anim animFlags) error {
+
+	if orderPath == "" {
+		return fmt.Errorf("replay needs a fill order: -order")
+	}
+	if at < 0 || at > 1 {
+		return fmt.Errorf("-at %v is outside 0 to 1", at)
+	}
+
+	save := defaultSaveOptions()
+	var err error
+	if format != "" {
+		if save.format, err = pixelart.LookupFormat(format); err != nil {
+			return err
+		}
+	} else if f, ok := pixelart.FormatForFile(name); ok {
+		save.format = f
+	}
+	if err = anim.apply(&save); err != nil {
+		return err
+	}
+
+	order, err := readFillOrder(orderPath, orderBits, orderSeeds)
+	if err != nil {
+		return err
+	}
+	filled := order.Filled()
+
+	var animation *pixelart.Animation
+	if save.animPath != "" {
+		animation = pixelart.NewAnimation(save.anim)
+	}
+
+	var pic image.Image
+	if imagePath != "" {
+		final, err := loadImage(imagePath)
+		if err != nil {
+			return err
+		}
+		if b := final.Bounds(); b.Dx() != order.Width || b.Dy() != order.Height {
+			return fmt.Errorf("%s is %dx%d but the fill order is %dx%d", imagePath, b.Dx(), b.Dy(), order.Width, order.Height)
+		}
+		end := int(at * float64(filled))
+		if animation != nil {
+			for k := 1; k <= save.animFrames; k++ {
+				if err = animation.AddFrame(order.Render(final, end*k/save.animFrames)); err != nil {
+					return err
+				}
+			}
+		}
+		pic = order.Render(final, end)
+	} else {
+		args := pixelart.NewGenerateArgs()
+		if args.ColourBasis, err = pixelart.ParseColourBasis(colourAxes); err != nil {
+			return err
+		}
+		args.Echospace = echospace
+		args.Blur = int32(blur)
+		args.FlipDraw = flipDraw
+		args.StartRed = seedColour >> 16
+		args.StartGreen = (seedColour >> 8) & 0xFF
+		args.StartBlue = seedColour & 0xFF
+		if seedImage != "" {
+			if args.SeedImage, err = loadImage(seedImage); err != nil {
+				return err
+			}
+		}
+		if palette != "" {
+			if args.Palette, err = loadImage(palette); err != nil {
+				return err
+			}
+		}
+		// Replay takes the size from the order, but the rest is checked
+		// as a run's would be
+		args.Width, args.Height = order.Width, order.Height
+		if err = args.Validate(); err != nil {
+			return err
+		}
+		args.Progress = func(p pixelart.Progress) {
+			fmt.Println(p)
+		}
+
+		var animErr error
+		if animation != nil {
+			args.Frames = append(args.Frames, pixelart.AnimFrameHook(animation, save.animFrames, filled, &animErr))
+		}
+		if pic, err = pixelart.Replay(context.Background(), order, args); err != nil {
+			return err
+		}
+		if animErr != nil {
+			return animErr
+		}
+	}
+
+	if animation != nil {
+		if err = drawAnimation(animation, save); err != nil {
+			return err
+		}
+	}
+	return draw(pic, name, save)
+}
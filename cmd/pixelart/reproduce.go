@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Kapura/pixelart"
+)
+
+// reproduceMain generates an image again from the settings in its PNG
+// metadata.
+func reproduceMain(argv []string) {
+	fs := flag.NewFlagSet("reproduce", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pixelart reproduce [flags] image.png")
+		fs.PrintDefaults()
+	}
+
+	var (
+		name      string
+		format    string
+		cpus      int
+		seedImage string
+		palette   string
+	)
+	fs.StringVar(&name, "name", "", "name of the new image. Defaults to the original's with .reproduced added")
+	fs.StringVar(&format, "format", "", "output format. Defaults to the extension of -name, or png")
+	fs.IntVar(&cpus, "cpus", 0, "amount of cpu's used. 0 means the number the original was made with")
+	fs.StringVar(&seedImage, "seed-image", "", "where the seed image is now, if it has moved")
+	fs.StringVar(&palette, "palette", "", "where the palette image is now, if it has moved")
+	fs.Parse(argv)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	args, save, err := reproduceArgs(fs.Arg(0), name, format, seedImage, palette)
+	if pixelart.AsValidationErrors(err) != nil {
+		printProblems(err)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if cpus != 0 {
+		args.CPUs = cpus
+	}
+	if args.RNGSeed == 0 {
+		fmt.Println("The original run had no RNG seed, so the new image will differ from it")
+	}
+	CLImain(args, save)
+}
+
+func reproduceArgs(path, name, format, seedImage, palette string) (args pixelart.GenerateArgs, save saveOptions, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	meta, err := pixelart.ReadPNGMetadata(file)
+	file.Close()
+	if err != nil {
+		return args, save, fmt.Errorf("%s: %v", path, err)
+	}
+	args, err = meta.Args(pixelart.NewGenerateArgs())
+	if err != nil {
+		return args, save, fmt.Errorf("%s: %v", path, err)
+	}
+
+	if seedImage != "" {
+		args.SeedImagePath = seedImage
+	}
+	if palette != "" {
+		args.PalettePath = palette
+	}
+	if err = loadArgsImages(&args); err != nil {
+		return
+	}
+	if err = args.Validate(); err != nil {
+		return
+	}
+
+	save = defaultSaveOptions()
+	if format != "" {
+		if save.format, err = pixelart.LookupFormat(format); err != nil {
+			return
+		}
+	} else if f, ok := pixelart.FormatForFile(name); ok {
+		save.format = f
+	}
+	if name == "" {
+		name = save.name(strings.TrimSuffix(path, filepath.Ext(path)) + ".reproduced")
+	}
+	args.Name = name
+	return args, save, nil
+}
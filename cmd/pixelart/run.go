@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Kapura/pixelart"
+)
+
+// applyCPUs sets GOMAXPROCS for the run. 0 keeps the go runtime's choice and
+// anything out of range means all of them.
+func applyCPUs(cpus int) int {
+	if cpus != 0 {
+		if (cpus < 0) || (cpus > runtime.NumCPU()) {
+			cpus = runtime.NumCPU()
+		}
+		runtime.GOMAXPROCS(cpus)
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// saveOptions say how images are written out.
+type saveOptions struct {
+	format pixelart.Format
+	encode pixelart.EncodeOptions
+
+	// an animation of the fill is made if animPath is set
+	animPath   string
+	animFrames int
+	anim       pixelart.AnimOptions
+
+	// frames are streamed every streamEvery pixels if streamPath is set;
+	// "-" is stdout
+	streamPath  string
+	streamEvery int
+	stream      pixelart.StreamOptions
+
+	// the fill order is written as an image of fillOrderBits if the name has
+	// an image extension, or in the binary format if not
+	fillOrderPath string
+	fillOrderBits int
+
+	// a JSON report of ImageStats and the fill's stats goes to statsPath
+	statsPath string
+
+	// the canvas is drawn in the terminal as it fills if preview.frames is
+	// set
+	preview termOptions
+
+	// snapshots of the canvas are saved as it fills if any of the triggers
+	// are set; the tag, format and encoding come from the run
+	snapshots pixelart.SnapshotOptions
+
+	// log is where the run's messages are printed: stdout, unless the
+	// frames are streamed there
+	log io.Writer
+}
+
+func defaultSaveOptions() saveOptions {
+	format, _ := pixelart.LookupFormat("png")
+	return saveOptions{format: format, animFrames: 100, streamEvery: 4096, log: os.Stdout}
+}
+
+// logf prints a line to save.log.
+func (save saveOptions) logf(format string, v ...interface{}) {
+	fmt.Fprintf(save.log, format+"\n", v...)
+}
+
+// setAnimPath picks the animation format from the extension of path.
+func (save *saveOptions) setAnimPath(path string) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gif":
+		save.anim.APNG = false
+	case ".png", ".apng":
+		save.anim.APNG = true
+	default:
+		return fmt.Errorf("cannot tell the animation format of %s, expected .gif, .png or .apng", path)
+	}
+	save.animPath = path
+	return nil
+}
+
+// name adds the format's extension to stem.
+func (save saveOptions) name(stem string) string {
+	return stem + "." + save.format.Extensions[0]
+}
+
+// run generates the image described by args and saves it, along with the
+// intermediate images if args.DrawIR is set.
+func run(ctx context.Context, args pixelart.GenerateArgs, save saveOptions) error {
+	// Also affects CPU scheduling I suppose :)
+	args.CPUs = applyCPUs(args.CPUs)
+
+	if args.ResumePath != "" {
+		var err error
+		args, err = pixelart.ResumeArgs(args)
+		if err != nil {
+			return err
+		}
+	}
+
+	if args.Name == "" {
+		args.Name = save.name(pixelart.ComposeImageName(args))
+	}
+
+	if args.CheckpointPath != "" && args.RNGSeed == 0 {
+		// Generate would pick one anyway, but it has to go in the metadata
+		args.RNGSeed = time.Now().UnixNano()
+	}
+	save.encode.Metadata = pixelart.ArgsMetadata(args)
+
+	snaps := save.snapshots
+	if args.DrawIR && !snaps.Snapshots() {
+		// the intermediate representations are a snapshot each update
+		snaps.Fractions = int(args.UpdateFreq)
+	}
+	var snapshotter *pixelart.Snapshotter
+	if snaps.Snapshots() {
+		snaps.Tag, snaps.Format, snaps.Encode = args.Tag, save.format, save.encode
+		var err error
+		if snapshotter, err = pixelart.NewSnapshotter(snaps, args.Width*args.Height); err != nil {
+			return err
+		}
+		args.Frames = append(args.Frames, snapshotter.Hook())
+	}
+
+	var anim *pixelart.Animation
+	var animErr error
+	if save.animPath != "" {
+		anim = pixelart.NewAnimation(save.anim)
+		args.Frames = append(args.Frames, pixelart.AnimFrameHook(anim, save.animFrames, args.Width*args.Height, &animErr))
+	}
+
+	var stream *pixelart.FrameStream
+	var streamErr error
+	if save.streamPath != "" {
+		out, err := openStream(save.streamPath)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		stream, err = pixelart.NewFrameStream(out, save.stream)
+		if err != nil {
+			return err
+		}
+		args.Frames = append(args.Frames, pixelart.StreamFrameHook(stream, save.streamEvery, &streamErr))
+	}
+
+	if save.fillOrderPath != "" && args.FillOrder == nil {
+		args.FillOrder = new(pixelart.FillOrder)
+	}
+	if save.statsPath != "" {
+		args.FillStats = new(pixelart.FillStats)
+	}
+
+	pic, err := pixelart.Generate(ctx, args)
+	if snapshotter != nil {
+		if serr := snapshotter.Close(); serr != nil && err == nil {
+			err = fmt.Errorf("snapshots: %v", serr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if args.FillStats != nil {
+		stats := pixelart.ImageStats(pic)
+		stats.Fill = args.FillStats
+		if err = writeJSON(stats, save); err != nil {
+			return err
+		}
+	}
+
+	if save.fillOrderPath != "" {
+		if err = drawFillOrder(args.FillOrder, save); err != nil {
+			return err
+		}
+	}
+
+	if stream != nil {
+		if streamErr == nil {
+			streamErr = stream.Flush()
+		}
+		if streamErr != nil {
+			return streamErr
+		}
+	}
+
+	if anim != nil {
+		if animErr != nil {
+			return animErr
+		}
+		if err = drawAnimation(anim, save); err != nil {
+			return err
+		}
+	}
+	return draw(pic, args.Name, save)
+}
+
+// openStream opens the file, named pipe or stdout that frames go to.
+// Closing stdout's leaves it open.
+func openStream(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// writeJSON saves v as indented JSON to save.statsPath, or prints it if
+// that's "-".
+func writeJSON(v interface{}, save saveOptions) error {
+	name := save.statsPath
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if name == "-" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	save.logf("Writing stats to %s", name)
+	return os.WriteFile(name, b, 0644)
+}
+
+func drawFillOrder(order *pixelart.FillOrder, save saveOptions) error {
+	name, bits := save.fillOrderPath, save.fillOrderBits
+	save.logf("Drawing fill order to %s", name)
+	var encode func(file *os.File) error
+	if format, ok := pixelart.FormatForFile(name); ok {
+		pic, err := order.Image(bits)
+		if err != nil {
+			return err
+		}
+		encode = func(file *os.File) error {
+			// the seed count goes in the metadata, where the format has any
+			return format.Encode(file, pic, pixelart.EncodeOptions{Metadata: order.Metadata()})
+		}
+	} else {
+		encode = func(file *os.File) error {
+			_, err := order.WriteTo(file)
+			return err
+		}
+	}
+
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err = encode(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// readFillOrder loads an order saved by drawFillOrder. An image's seed
+// count is seeds, or if that's negative what its metadata says.
+func readFillOrder(name string, bits, seeds int) (*pixelart.FillOrder, error) {
+	if format, ok := pixelart.FormatForFile(name); ok {
+		pic, err := loadImage(name)
+		if err != nil {
+			return nil, err
+		}
+		if seeds < 0 && format.Name == "png" {
+			seeds, err = fillOrderSeeds(name)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if seeds < 0 {
+			return nil, fmt.Errorf("%s doesn't say how many seeds its run had; give it with -order-seeds", name)
+		}
+		return pixelart.FillOrderFromImage(pic, bits, seeds)
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return pixelart.ReadFillOrder(file)
+}
+
+// fillOrderSeeds reads the seed count from a fill order PNG's metadata, or
+// -1 if it hasn't one.
+func fillOrderSeeds(name string) (int, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return -1, err
+	}
+	defer file.Close()
+	meta, err := pixelart.ReadPNGMetadata(file)
+	if err != nil {
+		return -1, fmt.Errorf("%s: %v", name, err)
+	}
+	if n, ok := meta.FillOrderSeeds(); ok {
+		return n, nil
+	}
+	return -1, nil
+}
+
+func drawAnimation(anim *pixelart.Animation, save saveOptions) error {
+	name := save.animPath
+	save.logf("Drawing %d frames to %s", anim.Len(), name)
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = anim.Encode(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// draw function for the final image
+func draw(pic image.Image, name string, save saveOptions) error {
+	save.logf("Drawing %s", name)
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = save.format.Encode(file, pic, save.encode)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,844 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Kapura/pixelart"
+)
+
+type jobState string
+
+const (
+	jobQueued    jobState = "queued"
+	jobRunning   jobState = "running"
+	jobDone      jobState = "done"
+	jobFailed    jobState = "failed"
+	jobCancelled jobState = "cancelled"
+)
+
+// job is a generation submitted to the server.
+type job struct {
+	id       string
+	dir      string
+	settings pixelart.Settings
+	args     pixelart.GenerateArgs
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	mu       sync.Mutex
+	state    jobState
+	err      string
+	progress pixelart.Progress
+	created  time.Time
+	started  time.Time
+	finished time.Time
+	// frames are the files of the intermediate images, in order, and final
+	// the finished image's once there is one
+	frames []string
+	final  string
+
+	// watchers are the channels of the clients streaming the job's events,
+	// and preview the last preview sent, for the ones that join late
+	watchers      map[chan jobEvent]bool
+	preview       *jobEvent
+	previewFilled int
+}
+
+// jobEvent is a server-sent event, its data being a single line of JSON.
+type jobEvent struct {
+	name string
+	data []byte
+}
+
+// previewEvent is the data of a "preview" event: a shrunken copy of the
+// canvas as a PNG.
+type previewEvent struct {
+	Filled int    `json:"filled"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	PNG    []byte `json:"png"`
+}
+
+// jobView is how a job is shown to clients.
+type jobView struct {
+	ID       string             `json:"id"`
+	State    jobState           `json:"state"`
+	Error    string             `json:"error,omitempty"`
+	Settings pixelart.Settings  `json:"settings"`
+	Width    int                `json:"width"`
+	Height   int                `json:"height"`
+	Progress *pixelart.Progress `json:"progress,omitempty"`
+	Created  time.Time          `json:"created"`
+	Started  *time.Time         `json:"started,omitempty"`
+	Finished *time.Time         `json:"finished,omitempty"`
+	Frames   int                `json:"frames"`
+	Image    string             `json:"image,omitempty"`
+}
+
+func (j *job) view() jobView {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.viewLocked()
+}
+
+func (j *job) viewLocked() jobView {
+	v := jobView{
+		ID:       j.id,
+		State:    j.state,
+		Error:    j.err,
+		Settings: j.settings,
+		Width:    j.args.Width,
+		Height:   j.args.Height,
+		Created:  j.created,
+		Frames:   len(j.frames),
+	}
+	if j.progress.Total > 0 {
+		p := j.progress
+		v.Progress = &p
+	}
+	if !j.started.IsZero() {
+		t := j.started
+		v.Started = &t
+	}
+	if !j.finished.IsZero() {
+		t := j.finished
+		v.Finished = &t
+	}
+	if j.final != "" {
+		v.Image = "/jobs/" + j.id + "/image"
+	}
+	return v
+}
+
+// start moves a queued job on to running, unless it was cancelled while it
+// waited.
+func (j *job) start() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state != jobQueued {
+		return false
+	}
+	j.state = jobRunning
+	j.started = time.Now()
+	return true
+}
+
+func (j *job) finish(state jobState, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.end(state, err)
+}
+
+// end records how the job finished and lets the watchers know, closing
+// their channels. j.mu is held.
+func (j *job) end(state jobState, err error) {
+	j.state = state
+	if err != nil {
+		j.err = err.Error()
+	}
+	j.finished = time.Now()
+	// the run is over, so the decoded images needn't be kept with it
+	j.args.SeedImage = nil
+	j.args.Palette = nil
+	j.publish("state", j.viewLocked())
+	for ch := range j.watchers {
+		close(ch)
+	}
+	j.watchers = nil
+}
+
+func (j *job) over() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return !j.finished.IsZero()
+}
+
+// stop cancels the job, whether it's waiting or running.
+func (j *job) stop() {
+	j.mu.Lock()
+	if j.state == jobQueued {
+		j.end(jobCancelled, nil)
+	}
+	j.mu.Unlock()
+	j.cancel()
+}
+
+// publish sends an event to the watchers. j.mu is held. A watcher that
+// isn't keeping up misses the event rather than hold up the fill.
+func (j *job) publish(name string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("job %s: cannot send %s event: %v", j.id, name, err)
+		return
+	}
+	ev := jobEvent{name, data}
+	if name == "preview" {
+		j.preview = &ev
+	}
+	for ch := range j.watchers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// watch subscribes to the job's events, giving the ones that bring a new
+// watcher up to date. The channel is nil if the job is already over.
+func (j *job) watch() (chan jobEvent, []jobEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	state, _ := json.Marshal(j.viewLocked())
+	catchUp := []jobEvent{{"state", state}}
+	if j.progress.Total > 0 {
+		progress, _ := json.Marshal(j.progress)
+		catchUp = append(catchUp, jobEvent{"progress", progress})
+	}
+	if j.preview != nil {
+		catchUp = append(catchUp, *j.preview)
+	}
+	if !j.finished.IsZero() {
+		return nil, catchUp
+	}
+	ch := make(chan jobEvent, 16)
+	if j.watchers == nil {
+		j.watchers = make(map[chan jobEvent]bool)
+	}
+	j.watchers[ch] = true
+	return ch, catchUp
+}
+
+func (j *job) unwatch(ch chan jobEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.watchers, ch)
+}
+
+// server runs the jobs posted to it, a few at a time, from a queue of
+// bounded length.
+type server struct {
+	dir   string
+	queue chan *job
+	// updates is how many previews a run sends, and previewSize how big
+	// they are
+	updates     int32
+	previewSize int
+	// keep is how many finished jobs are remembered; older ones are
+	// forgotten, though their images stay in dir
+	keep int
+
+	mu    sync.Mutex
+	jobs  map[string]*job
+	order []*job
+	next  int
+}
+
+func newServer(dir string, workers, queue int, updates int32, previewSize, keep int) *server {
+	s := &server{
+		dir:         dir,
+		queue:       make(chan *job, queue),
+		updates:     updates,
+		previewSize: previewSize,
+		keep:        keep,
+		jobs:        make(map[string]*job),
+	}
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	return s
+}
+
+func (s *server) work() {
+	for j := range s.queue {
+		if j.start() {
+			s.run(j)
+		}
+	}
+}
+
+func (s *server) run(j *job) {
+	defer j.cancel()
+	save := defaultSaveOptions()
+	args := j.args
+	args.Name = filepath.Join(j.dir, "final.png")
+	save.encode.Metadata = pixelart.ArgsMetadata(args)
+
+	args.Logf = func(format string, v ...interface{}) {
+		log.Printf("job %s: "+format, append([]interface{}{j.id}, v...)...)
+	}
+	args.Progress = func(p pixelart.Progress) {
+		j.mu.Lock()
+		j.progress = p
+		j.publish("progress", p)
+		j.mu.Unlock()
+	}
+	args.UpdateFreq = s.updates
+	args.Preview = pixelart.NewPreviewBuffer(s.previewSize)
+	args.Update = func(_ image.Image, p pixelart.Progress) {
+		if !p.Done {
+			s.sendPreview(j, args.Preview)
+		}
+	}
+	// the frames are copied at each update by the fill, then saved on
+	// goroutines of their own, one at a time; they're named by how far
+	// along they are
+	size := image.Pt(args.Width, args.Height)
+	if args.SeedImage != nil {
+		size = args.SeedImage.Bounds().Size()
+	}
+	var frames sync.Mutex
+	var saving sync.WaitGroup
+	args.Frames = append(args.Frames, pixelart.FrameHook{
+		Every: maxint(1, size.X*size.Y/int(s.updates)),
+		Frame: func(pic *image.RGBA, filled int) {
+			// the finished image is final.png
+			if filled >= size.X*size.Y {
+				return
+			}
+			saving.Add(1)
+			go func() {
+				defer saving.Done()
+				frames.Lock()
+				defer frames.Unlock()
+				name := filepath.Join(j.dir, fmt.Sprintf("frame.%010d.png", filled))
+				if err := draw(pic, name, save); err != nil {
+					args.Logf("cannot save frame: %v", err)
+					return
+				}
+				j.mu.Lock()
+				j.frames = append(j.frames, name)
+				sort.Strings(j.frames)
+				j.mu.Unlock()
+			}()
+		},
+	})
+
+	args.Logf("started")
+	pic, err := pixelart.Generate(j.ctx, args)
+	saving.Wait()
+	if err == nil {
+		err = draw(pic, args.Name, save)
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		j.finish(jobCancelled, nil)
+	case err != nil:
+		j.finish(jobFailed, err)
+	default:
+		j.mu.Lock()
+		j.final = args.Name
+		j.mu.Unlock()
+		j.finish(jobDone, nil)
+	}
+	args.Logf("%s", j.view().State)
+}
+
+// sendPreview sends the run's mipmap to the job's watchers, if it has
+// any.
+func (s *server) sendPreview(j *job, preview *pixelart.PreviewBuffer) {
+	j.mu.Lock()
+	watched := len(j.watchers) > 0
+	j.mu.Unlock()
+	if !watched {
+		return
+	}
+	// the mipmap's no bigger than previewSize, so it's sent as it is
+	thumb, filled := preview.Mipmap()
+	if thumb == nil {
+		return
+	}
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := enc.Encode(&buf, thumb); err != nil {
+		log.Printf("job %s: cannot encode preview: %v", j.id, err)
+		return
+	}
+	ev := previewEvent{filled, thumb.Bounds().Dx(), thumb.Bounds().Dy(), buf.Bytes()}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// previews come on goroutines of their own; don't let a slow one
+	// replace a newer one
+	if j.preview != nil && j.previewFilled >= filled {
+		return
+	}
+	j.previewFilled = filled
+	j.publish("preview", ev)
+}
+
+// submit queues a job with settings and the images uploaded for it, which
+// are the files readForm saved by the setting they're for. The uploads are
+// removed if the job isn't queued.
+func (s *server) submit(settings pixelart.Settings, uploads map[string]string) (j *job, err error) {
+	defer func() {
+		if err != nil {
+			for _, path := range uploads {
+				os.Remove(path)
+			}
+		}
+	}()
+	// they'd have the server write and read files of the client's choosing;
+	// the images come as uploads instead
+	for _, key := range []string{"checkpoint", "resume"} {
+		if _, ok := settings[key]; ok {
+			return nil, &pixelart.FieldError{Field: key, Message: "isn't allowed in a job"}
+		}
+	}
+	for _, key := range []string{"seed-image", "palette"} {
+		if _, ok := settings[key]; ok {
+			return nil, &pixelart.FieldError{Field: key, Message: "should be uploaded with the form, not named"}
+		}
+	}
+	// the job keeps the settings as they were sent, without the server's
+	// paths
+	withUploads := make(pixelart.Settings, len(settings)+len(uploads))
+	for key, v := range settings {
+		withUploads[key] = v
+	}
+	for key, path := range uploads {
+		withUploads[key] = path
+	}
+	args, err := withUploads.Args(pixelart.NewGenerateArgs())
+	if err != nil {
+		return nil, err
+	}
+	if err = loadArgsImages(&args); err != nil {
+		return nil, err
+	}
+	if err = args.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	j = &job{
+		id:       strconv.Itoa(s.next),
+		settings: settings,
+		args:     args,
+		state:    jobQueued,
+		created:  time.Now(),
+	}
+	j.dir = filepath.Join(s.dir, j.id)
+	if err = os.MkdirAll(j.dir, 0755); err != nil {
+		return nil, err
+	}
+	j.ctx, j.cancel = context.WithCancel(context.Background())
+
+	select {
+	case s.queue <- j:
+	default:
+		j.cancel()
+		os.Remove(j.dir)
+		s.next--
+		return nil, errQueueFull
+	}
+	s.jobs[j.id] = j
+	s.order = append(s.order, j)
+	s.forget()
+	return j, nil
+}
+
+// forget drops the oldest finished jobs past the s.keep most recent. s.mu
+// is held.
+func (s *server) forget() {
+	finished := 0
+	for _, j := range s.order {
+		if j.over() {
+			finished++
+		}
+	}
+	order := make([]*job, 0, len(s.order))
+	for _, j := range s.order {
+		if finished > s.keep && j.over() {
+			finished--
+			delete(s.jobs, j.id)
+			continue
+		}
+		order = append(order, j)
+	}
+	s.order = order
+}
+
+var errQueueFull = errors.New("the job queue is full, try again later")
+
+func (s *server) job(w http.ResponseWriter, r *http.Request) *job {
+	s.mu.Lock()
+	j := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if j == nil {
+		httpError(w, http.StatusNotFound, "no job %q", r.PathValue("id"))
+	}
+	return j
+}
+
+func (s *server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", s.handleSubmit)
+	mux.HandleFunc("GET /jobs", s.handleList)
+	mux.HandleFunc("GET /jobs/{id}", s.handleJob)
+	mux.HandleFunc("DELETE /jobs/{id}", s.handleCancel)
+	mux.HandleFunc("POST /jobs/{id}/cancel", s.handleCancel)
+	mux.HandleFunc("GET /jobs/{id}/image", s.handleImage)
+	mux.HandleFunc("GET /jobs/{id}/frames", s.handleFrames)
+	mux.HandleFunc("GET /jobs/{id}/frames.zip", s.handleFramesZip)
+	mux.HandleFunc("GET /jobs/{id}/frames/{n}", s.handleFrame)
+	mux.HandleFunc("GET /jobs/{id}/events", s.handleEvents)
+	mux.HandleFunc("GET /presets", handlePresets)
+	mux.Handle("GET /", webUI())
+	return mux
+}
+
+// handleSubmit takes a run file in JSON, as -config reads, or a form with
+// the run file in its settings field and the seed image and palette, if
+// any, uploaded alongside.
+func (s *server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var settings pixelart.Settings
+	var uploads map[string]string
+	var err error
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "multipart/form-data" {
+		settings, uploads, err = s.readForm(w, r)
+	} else {
+		var body []byte
+		if body, err = io.ReadAll(io.LimitReader(r.Body, 1<<20)); err == nil {
+			settings, err = pixelart.ParseSettings(body, "json")
+		}
+	}
+	if err != nil {
+		httpError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	j, err := s.submit(settings, uploads)
+	if err == errQueueFull {
+		httpError(w, http.StatusServiceUnavailable, "%v", err)
+		return
+	} else if fields := pixelart.AsValidationErrors(err); fields != nil {
+		fieldsError(w, fields)
+		return
+	} else if err != nil {
+		httpError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+j.id)
+	writeJSONResponse(w, http.StatusCreated, j.view())
+}
+
+// readForm reads a job form, saving its images to the uploads directory.
+// uploads has their paths by the setting they're for.
+func (s *server) readForm(w http.ResponseWriter, r *http.Request) (settings pixelart.Settings, uploads map[string]string, err error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+	if err = r.ParseMultipartForm(maxUpload); err != nil {
+		return nil, nil, err
+	}
+	defer r.MultipartForm.RemoveAll()
+	if settings, err = pixelart.ParseSettings([]byte(r.FormValue("settings")), "json"); err != nil {
+		return nil, nil, fmt.Errorf("settings: %v", err)
+	}
+	uploads = make(map[string]string)
+	defer func() {
+		if err != nil {
+			for _, path := range uploads {
+				os.Remove(path)
+			}
+		}
+	}()
+	for _, key := range []string{"seed-image", "palette"} {
+		file, header, err := r.FormFile(key)
+		if err == http.ErrMissingFile {
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		path, err := s.saveUpload(file, filepath.Ext(header.Filename))
+		file.Close()
+		if path != "" {
+			uploads[key] = path
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return settings, uploads, nil
+}
+
+const maxUpload = 64 << 20
+
+func (s *server) saveUpload(r io.Reader, ext string) (string, error) {
+	dir := filepath.Join(s.dir, "uploads")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	out, err := os.CreateTemp(dir, "*"+ext)
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(out, r); err == nil {
+		err = out.Close()
+	} else {
+		out.Close()
+	}
+	return out.Name(), err
+}
+
+func (s *server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	jobs := append([]*job(nil), s.order...)
+	s.mu.Unlock()
+	views := make([]jobView, len(jobs))
+	for i, j := range jobs {
+		views[i] = j.view()
+	}
+	writeJSONResponse(w, http.StatusOK, views)
+}
+
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	if j := s.job(w, r); j != nil {
+		writeJSONResponse(w, http.StatusOK, j.view())
+	}
+}
+
+func (s *server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	if j := s.job(w, r); j != nil {
+		j.stop()
+		writeJSONResponse(w, http.StatusOK, j.view())
+	}
+}
+
+func (s *server) handleImage(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	final := j.final
+	j.mu.Unlock()
+	if final == "" {
+		httpError(w, http.StatusNotFound, "job %s has no image yet", j.id)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	http.ServeFile(w, r, final)
+}
+
+func (s *server) handleFrames(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	urls := make([]string, len(j.frames))
+	for i := range j.frames {
+		urls[i] = fmt.Sprintf("/jobs/%s/frames/%d", j.id, i)
+	}
+	j.mu.Unlock()
+	writeJSONResponse(w, http.StatusOK, urls)
+}
+
+// handleFramesZip sends all the frames so far in one zip file.
+func (s *server) handleFramesZip(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	frames := append([]string(nil), j.frames...)
+	j.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="pixelart-%s-frames.zip"`, j.id))
+	zw := zip.NewWriter(w)
+	for _, name := range frames {
+		// PNGs are compressed already
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: filepath.Base(name), Method: zip.Store, Modified: time.Now()})
+		if err == nil {
+			err = copyFile(f, name)
+		}
+		if err != nil {
+			// too late for an error response; a broken zip says as much
+			log.Printf("job %s: cannot send frames: %v", j.id, err)
+			return
+		}
+	}
+	zw.Close()
+}
+
+func copyFile(w io.Writer, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func (s *server) handleFrame(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j == nil {
+		return
+	}
+	n, err := strconv.Atoi(r.PathValue("n"))
+	j.mu.Lock()
+	var name string
+	if err == nil && n >= 0 && n < len(j.frames) {
+		name = j.frames[n]
+	}
+	j.mu.Unlock()
+	if name == "" {
+		httpError(w, http.StatusNotFound, "job %s has no frame %q", j.id, r.PathValue("n"))
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	http.ServeFile(w, r, name)
+}
+
+// handleEvents streams the job's progress, previews and changes of state
+// as server-sent events until it's over or the client goes away.
+func (s *server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpError(w, http.StatusInternalServerError, "cannot stream from this connection")
+		return
+	}
+	ch, catchUp := j.watch()
+	if ch != nil {
+		defer j.unwatch(ch)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for _, ev := range catchUp {
+		writeEvent(w, ev)
+	}
+	flusher.Flush()
+	if ch == nil {
+		return
+	}
+
+	// a comment now and then keeps proxies from giving up on a quiet stream
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case ev, open := <-ch:
+			if !open {
+				return
+			}
+			writeEvent(w, ev)
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w io.Writer, ev jobEvent) {
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, ev.data)
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func httpError(w http.ResponseWriter, status int, format string, v ...interface{}) {
+	writeJSONResponse(w, status, map[string]string{"error": fmt.Sprintf(format, v...)})
+}
+
+// fieldsError reports settings a job can't run with, with the problem with
+// each by its setting's name for a form to show beside its fields.
+func fieldsError(w http.ResponseWriter, errs pixelart.ValidationErrors) {
+	fields := make(map[string]string, len(errs))
+	for _, e := range errs {
+		if _, ok := fields[e.Field]; !ok {
+			fields[e.Field] = e.Message
+		}
+	}
+	writeJSONResponse(w, http.StatusBadRequest, map[string]interface{}{"error": errs.Error(), "fields": fields})
+}
+
+// serveMain runs the job server until it's killed.
+func serveMain(argv []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pixelart serve [flags]")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "  POST   /jobs               submit a run file in JSON, or a form with the images")
+		fmt.Fprintln(fs.Output(), "  GET    /jobs               list the jobs")
+		fmt.Fprintln(fs.Output(), "  GET    /jobs/{id}          a job's state and progress")
+		fmt.Fprintln(fs.Output(), "  DELETE /jobs/{id}          cancel a job")
+		fmt.Fprintln(fs.Output(), "  GET    /jobs/{id}/image    the finished image")
+		fmt.Fprintln(fs.Output(), "  GET    /jobs/{id}/frames   the intermediate images so far")
+		fmt.Fprintln(fs.Output(), "  GET    /jobs/{id}/events   stream progress, previews and state as server-sent events")
+		fmt.Fprintln(fs.Output(), "  GET    /                   a page to run and watch jobs from")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+
+	var (
+		addr    string
+		dir     string
+		workers int
+		queue   int
+		updates int
+		preview int
+		keep    int
+	)
+	fs.StringVar(&addr, "addr", "localhost:8080", "address to listen on")
+	fs.StringVar(&dir, "dir", "", "directory to keep the jobs' images in. Defaults to a new temporary one")
+	fs.IntVar(&workers, "jobs", 1, "number of jobs run at once")
+	fs.IntVar(&queue, "queue", 16, "number of jobs that can wait to run")
+	fs.IntVar(&updates, "updates", 20, "number of previews and frames saved over a run")
+	fs.IntVar(&preview, "preview", 256, "size in pixels of the square the streamed previews fit in")
+	fs.IntVar(&keep, "keep", 100, "number of finished jobs listed; older ones are forgotten, their images left in -dir")
+	fs.Parse(argv)
+
+	if dir == "" {
+		var err error
+		if dir, err = os.MkdirTemp("", "pixelart"); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if updates < 1 {
+		updates = 1
+	}
+	applyCPUs(-1)
+
+	if keep < 0 {
+		keep = 0
+	}
+	s := newServer(dir, workers, queue, int32(updates), preview, keep)
+	log.Printf("Serving on http://%s/, keeping images in %s", addr, dir)
+	log.Fatal(http.ListenAndServe(addr, s.routes()))
+}
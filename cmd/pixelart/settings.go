@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Kapura/pixelart"
+)
+
+// presetDir holds the user's own presets, one run file each, named after
+// the preset.
+func presetDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pixelart", "presets")
+}
+
+// loadPreset finds the named preset among the built-in ones, then in
+// presetDir. A name with an extension is read as a run file.
+func loadPreset(name string) (pixelart.Settings, error) {
+	if s, ok := pixelart.Preset(name); ok {
+		return s, nil
+	}
+	if filepath.Ext(name) != "" {
+		return pixelart.ReadSettings(name)
+	}
+	if dir := presetDir(); dir != "" {
+		for _, ext := range []string{".json", ".toml", ".yaml", ".yml"} {
+			path := filepath.Join(dir, name+ext)
+			if _, err := os.Stat(path); err == nil {
+				return pixelart.ReadSettings(path)
+			}
+		}
+	}
+	return nil, fmt.Errorf("no preset called %q, the built-in ones are %s", name, strings.Join(pixelart.PresetNames(), ", "))
+}
+
+// applySettings fills in the flags of fs that weren't given on the command
+// line, from the preset and then the run file, so the run file wins over
+// the preset and the command line over both.
+func applySettings(fs *flag.FlagSet, preset, config string) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	var layers []pixelart.Settings
+	if preset != "" {
+		s, err := loadPreset(preset)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, s)
+	}
+	if config != "" {
+		s, err := pixelart.ReadSettings(config)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, s)
+	}
+
+	var problems pixelart.ValidationErrors
+	for _, s := range layers {
+		keys := make([]string, 0, len(s))
+		for key := range s {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if given[key] {
+				continue
+			}
+			if fs.Lookup(key) == nil {
+				problems = append(problems, &pixelart.FieldError{Field: key, Message: "unknown setting"})
+			} else if err := fs.Set(key, s[key]); err != nil {
+				problems = append(problems, &pixelart.FieldError{Field: key, Message: fmt.Sprintf("bad setting %q: %v", s[key], err)})
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// saveSettings writes the settings of args to a run file.
+func saveSettings(args pixelart.GenerateArgs, path string) error {
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if format == "yml" {
+		format = "yaml"
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = pixelart.WriteSettings(file, pixelart.ArgsSettings(args), format); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Kapura/pixelart"
+)
+
+// statsMain prints the stats of images as JSON, one line each. The fill's
+// stats only come from generate -stats, as they're gone once it's over.
+func statsMain(argv []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pixelart stats image...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(argv)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	for _, path := range fs.Args() {
+		pic, err := loadImage(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		b, err := json.Marshal(struct {
+			File string `json:"file"`
+			*pixelart.Stats
+		}{path, pixelart.ImageStats(pic)})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(string(b))
+	}
+}
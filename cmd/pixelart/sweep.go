@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/Kapura/pixelart"
+)
+
+// listFlag collects the values of a flag given more than once.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// sweepMain generates small images for every combination of the settings
+// it's asked to vary and lays them out on a contact sheet.
+func sweepMain(argv []string) {
+	fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pixelart sweep -vary blur=1..4 -vary colour-basis=rgb,bgr [flags]")
+		fs.PrintDefaults()
+	}
+
+	var (
+		vary     listFlag
+		set      listFlag
+		preset   string
+		config   string
+		parallel int
+		width    int
+		height   int
+		cell     int
+		cols     int
+		name     string
+		index    string
+		keep     bool
+	)
+	fs.Var(&vary, "vary", "setting to sweep, as name=a,b,c or name=from..to:step. May be repeated")
+	fs.Var(&set, "set", "setting to hold for every run, as name=value. May be repeated")
+	fs.StringVar(&preset, "preset", "", "named look every run starts from")
+	fs.StringVar(&config, "config", "", "run file every run starts from")
+	fs.IntVar(&parallel, "parallel", runtime.NumCPU(), "number of images generated at once")
+	fs.IntVar(&width, "width", 256, "width of each image")
+	fs.IntVar(&height, "height", 256, "height of each image")
+	fs.IntVar(&cell, "cell", 256, "largest side of each image on the contact sheet")
+	fs.IntVar(&cols, "cols", 0, "images to a row on the contact sheet. 0 for a square sheet")
+	fs.StringVar(&name, "name", "sweep.png", "name of the contact sheet")
+	fs.StringVar(&index, "index", "", "name of the CSV index. Defaults to the contact sheet's with .csv")
+	fs.BoolVar(&keep, "keep", false, "also save each image, numbered as in the index")
+	fs.Parse(argv)
+
+	if err := sweep(vary, set, preset, config, parallel, width, height, cell, cols, name, index, keep); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func sweep(vary, set []string, preset, config string, parallel, width, height, cell, cols int,
+	name, index string, keep bool) error {
+
+	if len(vary) == 0 {
+		return fmt.Errorf("nothing to sweep, give at least one -vary")
+	}
+	var axes []pixelart.SweepAxis
+	for _, v := range vary {
+		axis, err := pixelart.ParseSweepAxis(v)
+		if err != nil {
+			return err
+		}
+		axes = append(axes, axis)
+	}
+
+	base := pixelart.NewGenerateArgs()
+	base.Width, base.Height = width, height
+	var layers []pixelart.Settings
+	if preset != "" {
+		s, err := loadPreset(preset)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, s)
+	}
+	if config != "" {
+		s, err := pixelart.ReadSettings(config)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, s)
+	}
+	held := make(pixelart.Settings)
+	for _, kv := range set {
+		eq := strings.IndexByte(kv, '=')
+		if eq < 1 || !pixelart.IsSetting(kv[:eq]) {
+			return fmt.Errorf("-set %q should be a setting's name=value", kv)
+		}
+		held[kv[:eq]] = kv[eq+1:]
+	}
+	layers = append(layers, held)
+	for _, s := range layers {
+		var err error
+		if base, err = s.Args(base); err != nil {
+			return err
+		}
+	}
+	if err := loadArgsImages(&base); err != nil {
+		return err
+	}
+
+	// catch combinations that can't be made before any of them run
+	for _, s := range pixelart.SweepSettings(axes) {
+		args, err := s.Args(base)
+		if err == nil {
+			err = args.Validate()
+		}
+		if err != nil {
+			var label []string
+			for _, axis := range axes {
+				label = append(label, axis.Name+"="+s[axis.Name])
+			}
+			return fmt.Errorf("sweep run %s: %v", strings.Join(label, " "), err)
+		}
+	}
+
+	save := defaultSaveOptions()
+	if f, ok := pixelart.FormatForFile(name); ok {
+		save.format = f
+	}
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	if index == "" {
+		index = stem + ".csv"
+	}
+
+	total := len(pixelart.SweepSettings(axes))
+	fmt.Printf("Sweeping %d combinations, %d at a time\n", total, parallel)
+	var mu sync.Mutex
+	finished := 0
+	runs := pixelart.Sweep(context.Background(), base, axes, parallel, func(run *pixelart.SweepRun) {
+		mu.Lock()
+		defer mu.Unlock()
+		finished++
+		status := fmt.Sprintf("done in %s", run.Elapsed.Round(1e6))
+		if run.Err != nil {
+			status = "failed: " + run.Err.Error()
+		} else if keep {
+			run.File = save.name(fmt.Sprintf("%s.%03d", stem, run.Index))
+			if err := draw(run.Image, run.File, save); err != nil {
+				run.File = ""
+				status = "not saved: " + err.Error()
+			}
+		}
+		fmt.Printf("[%d/%d] %s %s\n", finished, total, strings.Join(run.Label(axes), " "), status)
+	})
+
+	if err := draw(pixelart.ContactSheet(runs, axes, cols, cell), name, save); err != nil {
+		return err
+	}
+	file, err := os.Create(index)
+	if err != nil {
+		return err
+	}
+	if err = pixelart.WriteSweepIndex(file, runs, axes); err != nil {
+		file.Close()
+		return err
+	}
+	fmt.Println("Index written to", index)
+	return file.Close()
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Kapura/pixelart"
+)
+
+// termOptions set up the terminal preview; it's off if frames is 0.
+type termOptions struct {
+	// width is in columns, 0 to take it from $COLUMNS
+	width  int
+	frames int
+}
+
+// termPreview draws a shrunken copy of the canvas in the terminal, two
+// pixels to a character with the upper half block, in 24-bit colour. Each
+// frame is drawn over the last, with a progress bar underneath.
+type termPreview struct {
+	out   io.Writer
+	cols  int
+	rows  int
+	total int
+
+	// messages can come from other goroutines than the fill's
+	mu       sync.Mutex
+	progress pixelart.Progress
+	filled   int
+	// picture is the last frame drawn, kept for redrawing it after a
+	// message, and lines how many lines it took up on screen
+	picture string
+	lines   int
+}
+
+// the sample grid each character's pixels average over
+const termSamples = 4
+
+func newTermPreview(out io.Writer, opts termOptions, width, height int) *termPreview {
+	cols := opts.width
+	if cols < 1 {
+		cols = envInt("COLUMNS", 80)
+	}
+	// leave room for the progress bar and a line of slack
+	maxRows := envInt("LINES", 40) - 2
+	cols = minint(cols, width)
+	rows := (cols*height/width + 1) / 2
+	if rows > maxRows {
+		rows = maxint(1, maxRows)
+		cols = maxint(1, minint(cols, 2*rows*width/height))
+	}
+	return &termPreview{out: out, cols: cols, rows: maxint(1, rows), total: width * height}
+}
+
+func envInt(name string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+func minint(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxint(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// hook asks for the frames the preview draws.
+func (t *termPreview) hook(frames int) pixelart.FrameHook {
+	return pixelart.FrameHook{
+		Every: maxint(1, t.total/frames),
+		Frame: t.frame,
+	}
+}
+
+func (t *termPreview) frame(pic *image.RGBA, filled int) {
+	picture := t.render(pic)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.picture = picture
+	t.filled = filled
+	t.draw()
+}
+
+// render averages a grid of samples over the pixels of each half of each
+// character, which is quick however big the picture is.
+func (t *termPreview) render(pic *image.RGBA) string {
+	b := pic.Bounds()
+	cell := func(col, row int) (r, g, bl int) {
+		x0 := b.Min.X + col*b.Dx()/t.cols
+		x1 := b.Min.X + (col+1)*b.Dx()/t.cols
+		y0 := b.Min.Y + row*b.Dy()/(2*t.rows)
+		y1 := b.Min.Y + (row+1)*b.Dy()/(2*t.rows)
+		n := 0
+		for i := 0; i < termSamples; i++ {
+			y := y0 + (2*i+1)*maxint(1, y1-y0)/(2*termSamples)
+			for j := 0; j < termSamples; j++ {
+				x := x0 + (2*j+1)*maxint(1, x1-x0)/(2*termSamples)
+				c := pic.RGBAAt(minint(x, b.Max.X-1), minint(y, b.Max.Y-1))
+				r, g, bl = r+int(c.R), g+int(c.G), bl+int(c.B)
+				n++
+			}
+		}
+		return r / n, g / n, bl / n
+	}
+
+	var sb strings.Builder
+	for row := 0; row < t.rows; row++ {
+		for col := 0; col < t.cols; col++ {
+			tr, tg, tb := cell(col, 2*row)
+			br, bg, bb := cell(col, 2*row+1)
+			fmt.Fprintf(&sb, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", tr, tg, tb, br, bg, bb)
+		}
+		sb.WriteString("\x1b[0m\n")
+	}
+	return sb.String()
+}
+
+// draw puts the picture and progress bar over the last ones. t.mu is held.
+func (t *termPreview) draw() {
+	var sb strings.Builder
+	t.erase(&sb)
+	sb.WriteString(t.picture)
+	sb.WriteString(t.bar())
+	sb.WriteString("\n")
+	io.WriteString(t.out, sb.String())
+	t.lines = strings.Count(t.picture, "\n") + 1
+}
+
+func (t *termPreview) erase(sb *strings.Builder) {
+	if t.lines > 0 {
+		fmt.Fprintf(sb, "\x1b[%dA\x1b[J", t.lines)
+	}
+}
+
+func (t *termPreview) bar() string {
+	fraction := float64(t.filled) / float64(t.total)
+	width := maxint(10, t.cols-32)
+	done := int(fraction * float64(width))
+	status := fmt.Sprintf("%5.1f%%", fraction*100)
+	if p := t.progress; p.Done {
+		status += " in " + p.Elapsed.Round(time.Second).String()
+	} else if p.PixelsPerSecond > 0 {
+		status += fmt.Sprintf(" %s px/s %s left", siCount(p.PixelsPerSecond), p.ETA.Round(time.Second))
+	}
+	return "[" + strings.Repeat("#", done) + strings.Repeat(".", width-done) + "] " + status
+}
+
+// siCount writes n with a k or M suffix.
+func siCount(n float64) string {
+	switch {
+	case n >= 1e6:
+		return fmt.Sprintf("%.1fM", n/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.0fk", n/1e3)
+	}
+	return fmt.Sprintf("%.0f", n)
+}
+
+// setProgress is the run's Progress callback.
+func (t *termPreview) setProgress(p pixelart.Progress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress = p
+	if p.Filled > t.filled {
+		t.filled = p.Filled
+	}
+	if t.picture != "" {
+		t.draw()
+	}
+}
+
+// logf prints a message above the preview.
+func (t *termPreview) logf(format string, v ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var sb strings.Builder
+	t.erase(&sb)
+	fmt.Fprintf(&sb, format+"\n", v...)
+	io.WriteString(t.out, sb.String())
+	t.lines = 0
+	if t.picture != "" {
+		t.draw()
+	}
+}
@@ -0,0 +1,556 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kapura/pixelart"
+	"github.com/google/gxui"
+	"github.com/google/gxui/drivers/gl"
+	"github.com/google/gxui/math"
+	"github.com/google/gxui/themes/light"
+)
+
+var (
+	FieldNames []string
+	ProgPic gxui.Image
+	Status gxui.Label
+	Driver gxui.Driver
+
+	SeedThumb gxui.Image
+	ChromaSwatch gxui.Image
+
+	ProgBar gxui.ProgressBar
+	RunButton gxui.Button
+	PauseButton gxui.Button
+	StopButton gxui.Button
+
+	// Running is the run in progress, if there is one. LastRun is the one
+	// in the preview and LastPic its finished image once it's done; until
+	// then the canvas is in LastRun's preview buffer. They're only touched
+	// on the driver's goroutine.
+	Running *guiRun
+	LastRun *guiRun
+	LastPic image.Image
+	LastArgs pixelart.GenerateArgs
+
+	// History is the runs made from the window, kept between sessions.
+	History *runHistory
+)
+
+// guiRun is a generation started from the window.
+type guiRun struct {
+	cancel context.CancelFunc
+	pause *pixelart.Pauser
+	// filled is how far along the preview is, as the updates can arrive
+	// out of order
+	filled int
+	// preview is the canvas as of the last update
+	preview *pixelart.PreviewBuffer
+
+	// for the history: what the run was made with, its final picture, and
+	// how it ended
+	args pixelart.GenerateArgs
+	started time.Time
+	elapsed time.Duration
+	final image.Image
+	ended bool
+	err error
+	recorded bool
+	// order is when each pixel was filled, for the inspector once the run's
+	// over
+	order *pixelart.FillOrder
+}
+
+type dataField struct {
+	label gxui.Label
+	input gxui.TextBox
+	err gxui.Label
+	layout gxui.LinearLayout
+}
+
+func (df *dataField) Get() string {
+	return df.input.Text()
+}
+
+func (df *dataField) Put(s string) {
+	df.input.SetText(s)
+}
+
+func (df *dataField) SetLabel(label string) {
+	df.label.SetText(label)
+}
+
+func (df *dataField) SetError(e string) {
+	df.err.SetText(e)
+}
+
+func makeDataField(theme gxui.Theme, label string) *dataField {
+	df := new(dataField)
+	df.label = theme.CreateLabel()
+	df.label.SetText(label)
+	df.input = theme.CreateTextBox()
+	df.err = theme.CreateLabel()
+	df.err.SetColor(gxui.Red)
+	df.layout = theme.CreateLinearLayout()
+	df.layout.SetDirection(gxui.LeftToRight)
+	df.layout.AddChild(df.label)
+	df.layout.AddChild(df.input)
+	df.layout.AddChild(df.err)
+
+	return df
+}
+
+
+func appMain(driver gxui.Driver) {
+	data := make(map[string]*dataField)
+	output := make(map[string]gxui.Label)
+
+	theme := light.CreateTheme(driver)
+
+	window := theme.CreateWindow(750, 512, "art")
+	window.OnClose(driver.Terminate)
+
+	h_layout := theme.CreateLinearLayout()
+	h_layout.SetDirection(gxui.LeftToRight)
+	window.AddChild(h_layout)
+
+	v_layout := theme.CreateLinearLayout()
+	v_layout.SetDirection(gxui.TopToBottom)
+	v_layout.SetHorizontalAlignment(gxui.AlignRight)
+	h_layout.AddChild(v_layout)
+
+	h_layout.AddChild(createPreview(theme, data))
+
+	for s := range FieldNames {
+		df := makeDataField(theme, FieldNames[s])
+		data[FieldNames[s]] = df
+		v_layout.AddChild(df.layout)
+	}
+	initialise(data)
+
+	// the seed image gets a button to browse for it and a thumbnail, the
+	// chroma key a swatch of its colour
+	seed_image := data["seed image"]
+	browse_button := theme.CreateButton()
+	browse_button.SetText("Browse")
+	browse_button.OnClick(func(gxui.MouseEvent) {
+		pickImage(theme, filepath.Dir(seed_image.Get()), func(path string) {
+			seed_image.Put(path)
+			showSeedImage(data)
+		})
+	})
+	seed_image.layout.AddChild(browse_button)
+	SeedThumb = theme.CreateImage()
+	SeedThumb.SetExplicitSize(math.Size{48, 48})
+	SeedThumb.SetAspectMode(gxui.AspectCorrectLetterbox)
+	seed_image.layout.AddChild(SeedThumb)
+
+	seed_chroma := data["seed chroma"]
+	ChromaSwatch = theme.CreateImage()
+	ChromaSwatch.SetExplicitSize(math.Size{16, 16})
+	ChromaSwatch.SetBorderPen(gxui.CreatePen(1, gxui.Color{0, 0, 0, 1}))
+	seed_chroma.layout.AddChild(ChromaSwatch)
+	seed_chroma.input.OnTextChanged(func([]gxui.TextBoxEdit) { updateSwatch(seed_chroma) })
+	updateSwatch(seed_chroma)
+
+	// seeds are placed by clicking on the preview, in the colour picked
+	v_layout.AddChild(createColourPicker(theme))
+	SeedsLabel = theme.CreateLabel()
+	v_layout.AddChild(SeedsLabel)
+	clear_button := theme.CreateButton()
+	clear_button.SetText("Clear seeds")
+	clear_button.OnClick(func(gxui.MouseEvent) { onClearSeeds(data) })
+	v_layout.AddChild(clear_button)
+	for _, name := range []string{"width", "height", "start X", "start Y", "seed colour"} {
+		data[name].input.OnTextChanged(func([]gxui.TextBoxEdit) { showPlacement(data) })
+	}
+
+	preset := makeDataField(theme, "preset")
+	preset.SetError(strings.Join(pixelart.PresetNames(), ", "))
+	v_layout.AddChild(preset.layout)
+
+	load_button := theme.CreateButton()
+	load_button.SetText("Load preset")
+	load_button.OnClick(func(gxui.MouseEvent) { onLoadPreset(preset, data) })
+	v_layout.AddChild(load_button)
+
+	// gxui buttons can't be greyed out, so Run is hidden while a run is
+	// going and Pause and Stop shown in its place
+	controls := theme.CreateLinearLayout()
+	controls.SetDirection(gxui.LeftToRight)
+
+	RunButton = theme.CreateButton()
+	RunButton.SetText("Run")
+	RunButton.OnClick(func(gxui.MouseEvent) { onRun(data, output) })
+	controls.AddChild(RunButton)
+
+	PauseButton = theme.CreateButton()
+	PauseButton.SetText("Pause")
+	PauseButton.OnClick(func(gxui.MouseEvent) { onPause() })
+	controls.AddChild(PauseButton)
+
+	StopButton = theme.CreateButton()
+	StopButton.SetText("Stop")
+	StopButton.OnClick(func(gxui.MouseEvent) { onStop() })
+	controls.AddChild(StopButton)
+
+	history_button := theme.CreateButton()
+	history_button.SetText("History")
+	history_button.OnClick(func(gxui.MouseEvent) { showHistory(theme, data) })
+	controls.AddChild(history_button)
+
+	save_button := theme.CreateButton()
+	save_button.SetText("Save As")
+	save_button.OnClick(func(gxui.MouseEvent) { onSaveAs(theme) })
+	controls.AddChild(save_button)
+
+	showRunning(false)
+
+	label_3 := theme.CreateLabel()
+	output["L3"] = label_3
+
+	v_layout.AddChild(controls)
+
+	ProgBar = theme.CreateProgressBar()
+	ProgBar.SetTarget(1000)
+	v_layout.AddChild(ProgBar)
+
+	Status = theme.CreateLabel()
+	v_layout.AddChild(Status)
+
+	Driver = driver
+	showPlacement(data)
+
+	var err error
+	if History, err = loadHistory(historyDir()); err != nil {
+		Status.SetText("Cannot load the run history: " + err.Error())
+	}
+}
+
+func onRun(data map[string]*dataField, output map[string]gxui.Label) {
+	if Running != nil {
+		return
+	}
+	valid, args := validate(data)
+	if valid {
+		ctx, cancel := context.WithCancel(context.Background())
+		// named here rather than by run, so the history knows where it went
+		args.CPUs = applyCPUs(args.CPUs)
+		if args.Name == "" {
+			args.Name = defaultSaveOptions().name(pixelart.ComposeImageName(args))
+		}
+		r := &guiRun{cancel: cancel, pause: new(pixelart.Pauser), filled: -1, args: args, started: time.Now()}
+		r.order = &pixelart.FillOrder{Distances: true}
+		args.FillOrder = r.order
+		r.preview = pixelart.NewPreviewBuffer(previewSize)
+		args.Preview = r.preview
+		args.Pause = r.pause
+		args.Update = r.updateProgress
+		args.Progress = r.updateStatus
+
+		Running = r
+		LastRun = r
+		LastPic = nil
+		LastArgs = args
+		ProgBar.SetProgress(0)
+		showRunning(true)
+
+		go func() {
+			err := run(ctx, args, defaultSaveOptions())
+			cancel()
+			elapsed := time.Since(r.started)
+			Driver.Call(func() {
+				Running = nil
+				showRunning(false)
+				r.ended, r.err, r.elapsed = true, err, elapsed
+				r.recordRun()
+				switch {
+				case errors.Is(err, context.Canceled):
+					Status.SetText("Stopped")
+				case err != nil:
+					Status.SetText(err.Error())
+				}
+			})
+		}()
+	}
+
+}
+
+func showRunning(running bool) {
+	RunButton.SetVisible(!running)
+	PauseButton.SetVisible(running)
+	PauseButton.SetText("Pause")
+	StopButton.SetVisible(running)
+}
+
+func onPause() {
+	if Running == nil {
+		return
+	}
+	if Running.pause.Paused() {
+		Running.pause.Resume()
+		PauseButton.SetText("Pause")
+		Status.SetText("Resumed")
+	} else {
+		Running.pause.Pause()
+		PauseButton.SetText("Resume")
+		Status.SetText("Paused")
+	}
+}
+
+func onStop() {
+	if Running != nil {
+		Running.cancel()
+	}
+}
+
+// onSaveAs saves the picture in the preview as it is now, in the format
+// of the extension it's given.
+func onSaveAs(theme gxui.Theme) {
+	pic, args := LastPic, LastArgs
+	if pic == nil && LastRun != nil && LastRun.preview != nil {
+		if snap, _ := LastRun.preview.Snapshot(); snap != nil {
+			pic = snap
+		}
+	}
+	if pic == nil {
+		Status.SetText("Nothing to save yet")
+		return
+	}
+	name := args.Name
+	if name == "" {
+		name = defaultSaveOptions().name(pixelart.ComposeImageName(args))
+	}
+	saveImageAs(theme, name, func(path string) error {
+		save := defaultSaveOptions()
+		if f, ok := pixelart.FormatForFile(path); ok {
+			save.format = f
+		}
+		save.encode.Metadata = pixelart.ArgsMetadata(args)
+		if err := draw(pic, path, save); err != nil {
+			return err
+		}
+		Status.SetText("Saved " + path)
+		return nil
+	})
+}
+
+// guiFields maps the settings in presets and run files to the fields that
+// show them.
+var guiFields = map[string]string{
+	"chan":            "chan size",
+	"blur":            "blur",
+	"cpus":            "cpus",
+	"colour-basis":    "colour basis",
+	"es":              "echospacing",
+	"flip-draw":       "flip draw",
+	"ir":              "intermediate steps",
+	"seed":            "seed colour",
+	"seed-image":      "seed image",
+	"seed-rr":         "seed culling rate",
+	"seed-chroma-key": "seed chroma",
+	"seed-dupes":      "seed duplicates",
+	"seed-x":          "start X",
+	"seed-y":          "start Y",
+	"tag":             "tag",
+	"width":           "width",
+	"height":          "height",
+}
+
+func onLoadPreset(preset *dataField, data map[string]*dataField) {
+	s, err := loadPreset(strings.TrimSpace(preset.Get()))
+	if err != nil {
+		preset.SetError(err.Error())
+		return
+	}
+	preset.SetError("")
+	if err = putSettings(s, data, false); err != nil {
+		preset.SetError(err.Error())
+	}
+}
+
+// putSettings fills in the fields s has settings for. With all, it's a
+// whole run's settings, and the seed image and seeds it doesn't have are
+// taken away.
+func putSettings(s pixelart.Settings, data map[string]*dataField, all bool) (err error) {
+	for key, value := range s {
+		if field, ok := guiFields[key]; ok {
+			data[field].Put(value)
+		}
+	}
+	if _, ok := s["seed-image"]; ok || all {
+		data["seed image"].Put(s["seed-image"])
+		showSeedImage(data)
+	}
+	if seeds, ok := s["seeds"]; ok || all {
+		ExtraSeeds, err = pixelart.ParseSeeds(seeds)
+		showPlacement(data)
+	}
+	return err
+}
+
+// showSeedImage loads the seed image field's image into the thumbnail and
+// the size fields, or clears the thumbnail if there isn't one.
+func showSeedImage(data map[string]*dataField) (image.Image, error) {
+	path := strings.TrimSpace(data["seed image"].Get())
+	SeedPic = nil
+	if path == "" {
+		SeedThumb.SetTexture(nil)
+		data["seed image"].SetError("")
+		return nil, nil
+	}
+	pic, err := loadImage(path)
+	if err != nil {
+		SeedThumb.SetTexture(nil)
+		data["seed image"].SetError(err.Error())
+		return nil, err
+	}
+	data["seed image"].SetError("")
+	SeedPic = pic
+	SeedThumb.SetTexture(Driver.CreateTexture(pixelart.Thumbnail(pic, 48), 1))
+	// the seed image decides the size, as it does on the command line
+	data["width"].Put(strconv.Itoa(pic.Bounds().Max.X))
+	data["height"].Put(strconv.Itoa(pic.Bounds().Max.Y))
+	return pic, nil
+}
+
+// updateSwatch shows the colour in a chroma key field, or nothing if it
+// isn't one.
+func updateSwatch(df *dataField) {
+	c, err := strconv.ParseInt(strings.TrimSpace(df.Get()), 0, 0)
+	if err != nil || c < 0 || c > 0xFFFFFF {
+		ChromaSwatch.SetBackgroundBrush(gxui.CreateBrush(gxui.Color{}))
+		return
+	}
+	ChromaSwatch.SetBackgroundBrush(gxui.CreateBrush(gxui.Color{
+		R: float32(c>>16) / 255,
+		G: float32(c>>8&0xFF) / 255,
+		B: float32(c&0xFF) / 255,
+		A: 1,
+	}))
+}
+
+func initialise(data map[string]*dataField) {
+	data["chan size"].Put("8")
+	data["blur"].Put("1")
+	data["cpus"].Put(fmt.Sprint(runtime.NumCPU()))
+	data["update freq"].Put("10")
+	data["colour basis"].Put("rgb")
+	data["echospacing"].Put("0")
+	data["flip draw"].Put("false")
+	data["intermediate steps"].Put("false")
+	data["seed colour"].Put("0x000000")
+	data["seed image"].Put("")
+	data["seed chroma"].Put("0xFF00FF")
+	data["seed duplicates"].Put("false")
+	data["seed culling rate"].Put("0")
+	data["start X"].Put("0")
+	data["start Y"].Put("0")
+	data["tag"].Put("art")
+	data["width"].Put("4096")
+	data["height"].Put("4096")
+}
+
+func validate(data map[string]*dataField) (valid bool, args pixelart.GenerateArgs) {
+	for _, field := range guiFields {
+		data[field].SetError("")
+	}
+	data["update freq"].SetError("")
+
+	// before the size, which a seed image sets
+	// which shows its own error
+	seed_pic, seed_err := showSeedImage(data)
+
+	settings := pixelart.Settings{}
+	for key, field := range guiFields {
+		settings[key] = strings.TrimSpace(data[field].Get())
+	}
+	args, err := settings.Args(pixelart.NewGenerateArgs())
+	problems := pixelart.AsValidationErrors(err)
+
+	uf, err := strconv.Atoi(strings.TrimSpace(data["update freq"].Get()))
+	if err != nil {
+		problems = append(problems, &pixelart.FieldError{Field: "update-freq", Message: "should be a number"})
+	}
+	args.UpdateFreq = int32(uf)
+	args.SeedImage = seed_pic
+	args.Seeds = append([]pixelart.SeedPixel(nil), ExtraSeeds...)
+
+	problems = append(problems, pixelart.AsValidationErrors(args.Validate())...)
+	marked := make(map[string]bool)
+	for _, p := range problems {
+		field, ok := guiFields[p.Field]
+		if p.Field == "update-freq" {
+			field, ok = "update freq", true
+		}
+		if !ok {
+			// the seeds placed on the preview have no field of their own
+			Status.SetText(p.Error())
+		} else if !marked[field] {
+			data[field].SetError(p.Message)
+			marked[field] = true
+		}
+	}
+	return seed_err == nil && len(problems) == 0, args
+}
+
+func (r *guiRun) updateProgress(pic image.Image, p pixelart.Progress) {
+	Driver.Call(func(){
+		if p.Done {
+			r.final = pic
+			r.recordRun()
+		}
+		// a late update from an earlier run, or one overtaken
+		if LastRun != r || p.Filled < r.filled || r.final != nil && !p.Done {
+			return
+		}
+		r.filled = p.Filled
+		if pic == nil {
+			// mid-run, the picture's in the preview buffer
+			showBuffer(r.preview)
+			return
+		}
+		LastPic = pic
+		showPicture(pic)
+	})
+}
+
+func (r *guiRun) updateStatus(p pixelart.Progress) {
+	Driver.Call(func() {
+		ProgBar.SetProgress(int(p.Fraction() * 1000))
+		Status.SetText(p.String())
+	})
+}
+
+func GUImain() {
+	FieldNames = []string{
+		"chan size",
+		"blur",
+		"cpus",			// 1- MAX_CPUS (8?)
+		"update freq",
+		"colour basis",	// any of rgb
+		"echospacing",	// float between 0 and 1 determining how much to progress through set before ES
+		"flip draw", 	// technically a bool
+		"intermediate steps",	// also a bool
+		"seed colour",	// any of 0x000000 - 0xFFFFFF
+		"seed chroma",	// colour to ignore in initial image
+		"seed duplicates", // bool; attemp to reseed seen colours
+		"seed image",	// uploaded image
+		"seed culling rate", // % of pixels to reject from seed image
+		"start X",
+		"start Y",
+		"tag",
+		"width",
+		"height",
+	}
+	gl.StartDriver(appMain)
+
+}
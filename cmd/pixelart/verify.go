@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Kapura/pixelart"
+)
+
+// verifyMain checks that images use each colour exactly once. It exits
+// with 1 if any of them don't.
+func verifyMain(argv []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pixelart verify [flags] image...")
+		fs.PrintDefaults()
+	}
+
+	var (
+		palette string
+		bits    int
+		limit   int
+		asJSON  bool
+	)
+	fs.StringVar(&palette, "palette", "", "image whose colours should each be used once, instead of the RGB cube")
+	fs.IntVar(&bits, "bits", 8, "bits per channel of the RGB cube, for images smaller than 4096x4096")
+	fs.IntVar(&limit, "list", 10, "most colours to list of each kind of problem. -1 for all of them")
+	fs.BoolVar(&asJSON, "json", false, "print a JSON report for each image, one per line")
+	fs.Parse(argv)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	opts := pixelart.VerifyOptions{Bits: bits, Limit: limit}
+	if palette != "" {
+		var err error
+		if opts.Palette, err = loadImage(palette); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	status := 0
+	for _, path := range fs.Args() {
+		v, err := verifyImage(path, opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if !v.Exact {
+			status = 1
+		}
+		if asJSON {
+			b, err := json.Marshal(struct {
+				File string `json:"file"`
+				*pixelart.Verification
+			}{path, v})
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			fmt.Println(string(b))
+		} else {
+			printVerification(path, v)
+		}
+	}
+	os.Exit(status)
+}
+
+func verifyImage(path string, opts pixelart.VerifyOptions) (*pixelart.Verification, error) {
+	pic, err := loadImage(path)
+	if err != nil {
+		return nil, err
+	}
+	v, err := pixelart.Verify(pic, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return v, nil
+}
+
+func printVerification(path string, v *pixelart.Verification) {
+	verdict := "FAIL"
+	if v.Exact {
+		verdict = "OK"
+	}
+	fmt.Printf("%s: %s, %dx%d, %d distinct colours of %d\n", path, verdict, v.Width, v.Height, v.Distinct, v.Universe)
+	if v.Duplicated > 0 {
+		fmt.Printf("  %d colours used more than once, %d extra pixels\n", v.Duplicated, v.DuplicatePixels)
+		for _, c := range v.Duplicates {
+			fmt.Printf("    %s x%d\n", c.Colour, c.Count)
+		}
+	}
+	if v.Missing > 0 {
+		fmt.Printf("  %d colours missing\n", v.Missing)
+		for _, c := range v.MissingColours {
+			fmt.Printf("    %s\n", c)
+		}
+	}
+	if v.Outside > 0 {
+		fmt.Printf("  %d colours outside the palette or cube, on %d pixels\n", v.Outside, v.OutsidePixels)
+		for _, c := range v.OutsideColours {
+			fmt.Printf("    %s x%d\n", c.Colour, c.Count)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+"use strict";
+
+// The form's fields are named after the settings of a run file, so what it
+// posts is a run file, with the seed image and palette alongside.
+
+const form = document.getElementById("run");
+const formError = document.getElementById("form-error");
+const preview = document.getElementById("preview");
+const progress = document.getElementById("progress");
+const status = document.getElementById("status");
+const cancel = document.getElementById("cancel");
+const jobsBody = document.querySelector("#jobs tbody");
+
+const fileFields = ["seed-image", "palette"];
+const colourFields = ["seed", "seed-chroma-key"];
+
+let watching = null; // the job being streamed, and its EventSource
+let presets = {};
+
+function colourSetting(hex) {
+	return "0x" + hex.slice(1).toUpperCase();
+}
+
+function colourInput(setting) {
+	const n = Number(setting);
+	return "#" + (Number.isFinite(n) ? n : 0).toString(16).padStart(6, "0").slice(-6);
+}
+
+function settingsOf(form) {
+	const settings = {};
+	for (const el of form.elements) {
+		if (!el.name || el.name === "preset" || fileFields.includes(el.name)) {
+			continue;
+		}
+		if (el.type === "checkbox") {
+			settings[el.name] = String(el.checked);
+		} else if (colourFields.includes(el.name)) {
+			settings[el.name] = colourSetting(el.value);
+		} else {
+			settings[el.name] = el.value.trim();
+		}
+	}
+	return settings;
+}
+
+function applySettings(form, settings) {
+	for (const [key, value] of Object.entries(settings)) {
+		const el = form.elements[key];
+		if (!el || fileFields.includes(key)) {
+			continue;
+		}
+		if (el.type === "checkbox") {
+			el.checked = value === "true";
+		} else if (colourFields.includes(key)) {
+			el.value = colourInput(value);
+		} else {
+			el.value = value;
+		}
+	}
+}
+
+// check does what the browser's own validation would, plus the rules that
+// tie fields together, and marks the fields that are wrong. The server
+// checks again.
+function check(form) {
+	const problems = [];
+	for (const el of form.elements) {
+		el.classList.remove("invalid");
+		if (el.willValidate && !el.checkValidity()) {
+			el.classList.add("invalid");
+			problems.push(`${el.name}: ${el.validationMessage}`);
+		}
+	}
+	const seeded = form.elements["seed-image"].files.length > 0;
+	const width = Number(form.elements.width.value);
+	const height = Number(form.elements.height.value);
+	for (const [key, size] of [["seed-x", width], ["seed-y", height]]) {
+		const el = form.elements[key];
+		if (!seeded && Number(el.value) >= size) {
+			el.classList.add("invalid");
+			problems.push(`${key}: must be inside the image, below ${size}`);
+		}
+	}
+	const seeds = form.elements.seeds;
+	for (const seed of seeds.value.trim().split(/\s+/).filter(Boolean)) {
+		const [x, y] = seed.split(",").map(Number);
+		if (!seeded && (x >= width || y >= height)) {
+			seeds.classList.add("invalid");
+			problems.push(`seeds: ${seed} is outside the image`);
+		}
+	}
+	return problems;
+}
+
+form.elements.preset.addEventListener("change", (e) => {
+	const p = presets[e.target.value];
+	if (p) {
+		applySettings(form, p);
+	}
+});
+
+form.addEventListener("submit", async (e) => {
+	e.preventDefault();
+	const problems = check(form);
+	formError.hidden = problems.length === 0;
+	formError.textContent = problems.join("\n");
+	if (problems.length > 0) {
+		return;
+	}
+
+	const body = new FormData();
+	body.append("settings", JSON.stringify(settingsOf(form)));
+	for (const key of fileFields) {
+		const files = form.elements[key].files;
+		if (files.length > 0) {
+			body.append(key, files[0]);
+		}
+	}
+	const resp = await fetch("jobs", { method: "POST", body });
+	const job = await resp.json();
+	if (!resp.ok) {
+		// the server names the settings it turned down
+		for (const key of Object.keys(job.fields || {})) {
+			const el = form.elements[key];
+			if (el) {
+				el.classList.add("invalid");
+			}
+		}
+		formError.hidden = false;
+		formError.textContent = job.fields
+			? Object.entries(job.fields).map(([key, msg]) => `${key}: ${msg}`).join("\n")
+			: job.error;
+		return;
+	}
+	watch(job.id);
+	refreshJobs();
+});
+
+cancel.addEventListener("click", () => {
+	if (watching) {
+		fetch(`jobs/${watching.id}/cancel`, { method: "POST" });
+	}
+});
+
+function watch(id) {
+	if (watching) {
+		watching.events.close();
+	}
+	preview.removeAttribute("src");
+	progress.value = 0;
+	status.textContent = `Job ${id} is waiting to run.`;
+	cancel.hidden = false;
+
+	const events = new EventSource(`jobs/${id}/events`);
+	watching = { id, events };
+	events.addEventListener("progress", (e) => {
+		const p = JSON.parse(e.data);
+		progress.value = p.fraction;
+		status.textContent = p.done
+			? `Job ${id}: ${p.filled} pixels filled in ${p.elapsed_seconds.toFixed(1)}s.`
+			: `Job ${id}: ${(p.fraction * 100).toFixed(1)}% filled, ` +
+				`${Math.round(p.pixels_per_second)} px/s, about ${Math.round(p.eta_seconds)}s to go.`;
+	});
+	events.addEventListener("preview", (e) => {
+		const p = JSON.parse(e.data);
+		preview.src = "data:image/png;base64," + p.png;
+	});
+	events.addEventListener("state", (e) => {
+		const job = JSON.parse(e.data);
+		if (job.state === "done") {
+			preview.src = job.image;
+		} else if (job.state === "failed") {
+			status.textContent = `Job ${id} failed: ${job.error}`;
+		} else if (job.state === "cancelled") {
+			status.textContent = `Job ${id} was cancelled.`;
+		}
+		if (job.finished) {
+			events.close();
+			cancel.hidden = true;
+		}
+		refreshJobs();
+	});
+}
+
+function download(href, name, text) {
+	const a = document.createElement("a");
+	a.href = href;
+	a.download = name;
+	a.textContent = text;
+	return a;
+}
+
+async function refreshJobs() {
+	const resp = await fetch("jobs");
+	if (!resp.ok) {
+		return;
+	}
+	const jobs = await resp.json();
+	jobsBody.replaceChildren();
+	for (const job of jobs.reverse()) {
+		const tr = document.createElement("tr");
+		const id = document.createElement("td");
+		const link = document.createElement("a");
+		link.href = "#";
+		link.textContent = job.id;
+		link.addEventListener("click", (e) => {
+			e.preventDefault();
+			applySettings(form, job.settings);
+			watch(job.id);
+		});
+		id.append(link);
+
+		const state = document.createElement("td");
+		state.textContent = job.error ? `${job.state}: ${job.error}` : job.state;
+		const size = document.createElement("td");
+		size.textContent = `${job.width}×${job.height}`;
+
+		const links = document.createElement("td");
+		if (job.image) {
+			links.append(download(job.image, `pixelart-${job.id}.png`, "image"), " ");
+		}
+		const runFile = new Blob([JSON.stringify(job.settings, null, 2)], { type: "application/json" });
+		links.append(download(URL.createObjectURL(runFile), `pixelart-${job.id}.json`, "run file"));
+		if (job.frames > 0) {
+			links.append(" ", download(`jobs/${job.id}/frames.zip`, `pixelart-${job.id}-frames.zip`, `${job.frames} frames`));
+		}
+		tr.append(id, state, size, links);
+		jobsBody.append(tr);
+	}
+}
+
+async function loadPresets() {
+	const resp = await fetch("presets");
+	if (!resp.ok) {
+		return;
+	}
+	presets = await resp.json();
+	const select = form.elements.preset;
+	for (const name of Object.keys(presets).sort()) {
+		const opt = document.createElement("option");
+		opt.textContent = name;
+		select.append(opt);
+	}
+}
+
+loadPresets();
+refreshJobs();
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/Kapura/pixelart"
+)
+
+// web holds the page the serve command offers browsers, which drives the
+// same job API as any other client.
+//
+//go:embed web
+var web embed.FS
+
+func webUI() http.Handler {
+	root, err := fs.Sub(web, "web")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServerFS(root)
+}
+
+// handlePresets lists the built-in presets' settings by name.
+func handlePresets(w http.ResponseWriter, r *http.Request) {
+	presets := make(map[string]pixelart.Settings)
+	for _, name := range pixelart.PresetNames() {
+		presets[name], _ = pixelart.Preset(name)
+	}
+	writeJSONResponse(w, http.StatusOK, presets)
+}
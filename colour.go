@@ -1,12 +1,12 @@
-package main
+package pixelart
 
 import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/png"
 	"math"
-	"os"
+	"strconv"
+	"strings"
 )
 
 type Colour interface {
@@ -45,6 +45,35 @@ func NewSeedPixel(r, g, b uint8, x, y int) (spixel SeedPixel) {
 	return
 }
 
+// FormatSeeds writes seeds as "x,y,0xRRGGBB" separated by spaces, the way
+// the seeds setting has them.
+func FormatSeeds(seeds []SeedPixel) string {
+	parts := make([]string, len(seeds))
+	for i, sp := range seeds {
+		parts[i] = fmt.Sprintf("%d,%d,0x%02X%02X%02X", sp.Pt.X, sp.Pt.Y, sp.Colour.red, sp.Colour.green, sp.Colour.blue)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseSeeds is the inverse of FormatSeeds.
+func ParseSeeds(s string) ([]SeedPixel, error) {
+	var seeds []SeedPixel
+	for _, part := range strings.Fields(s) {
+		fields := strings.Split(part, ",")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("seed %q should look like x,y,0xRRGGBB", part)
+		}
+		x, err1 := strconv.Atoi(fields[0])
+		y, err2 := strconv.Atoi(fields[1])
+		c, err3 := strconv.ParseUint(fields[2], 0, 24)
+		if err1 != nil || err2 != nil || err3 != nil {
+			return nil, fmt.Errorf("seed %q should look like x,y,0xRRGGBB", part)
+		}
+		seeds = append(seeds, NewSeedPixel(uint8(c>>16), uint8(c>>8), uint8(c), x, y))
+	}
+	return seeds, nil
+}
+
 func (c SeedPixel) Red() int32 {
 	return c.Colour.Red()
 }
@@ -84,37 +113,57 @@ func (c *Pixel) RGBA(flip_draw bool) *color.RGBA {
 	}
 }
 
-// representation of the image as a 2D array
-type PixelArray [MaxWidth][MaxHeight]Pixel
+// representation of the image as a 2D array, column by column
+type PixelArray [][]Pixel
 
+// NewPixelArray makes a blank canvas, in one allocation the size of the
+// image rather than of the largest one there can be.
+func NewPixelArray(width, height int) *PixelArray {
+	pixels := make([]Pixel, width*height)
+	p := make(PixelArray, width)
+	for x := range p {
+		p[x] = pixels[x*height : (x+1)*height]
+	}
+	return &p
+}
+
+// ImageNRGBA copies the canvas into a picture, straight into its pixels.
 func (p *PixelArray) ImageNRGBA(width, height int, flip_draw bool) *image.RGBA {
 	pic := image.NewRGBA(image.Rect(0, 0, width, height))
+	var flip uint8
+	if flip_draw {
+		flip = 0xFF
+	}
 	for x := 0; x < width; x++ {
+		column := (*p)[x]
 		for y := 0; y < height; y++ {
-			pic.Set(x, y, p[x][y].RGBA(flip_draw))
+			c := column[y].Colour
+			i := pic.PixOffset(x, y)
+			pic.Pix[i], pic.Pix[i+1], pic.Pix[i+2], pic.Pix[i+3] = c.red^flip, c.green^flip, c.blue^flip, FullAlpha
 		}
 	}
 	return pic
 }
 
 func (p *PixelArray) Set(x, y int32, c Colour) {
-	p[x][y].Colour.red = uint8(c.Red())
-	p[x][y].Colour.green = uint8(c.Green())
-	p[x][y].Colour.blue = uint8(c.Blue())
-	p[x][y].Queued = true
-	p[x][y].Filled = true
+	px := &(*p)[x][y]
+	px.Colour.red = uint8(c.Red())
+	px.Colour.green = uint8(c.Green())
+	px.Colour.blue = uint8(c.Blue())
+	px.Queued = true
+	px.Filled = true
 }
 
 func (p *PixelArray) ColourAt(x, y int32) Colour24 {
-	return p[x][y].Colour
+	return (*p)[x][y].Colour
 }
 
 func (p *PixelArray) FilledAt(x, y int32) bool {
-	return p[x][y].Filled
+	return (*p)[x][y].Filled
 }
 
 func (p *PixelArray) QueuedAt(x, y int32) bool {
-	return p[x][y].Queued
+	return (*p)[x][y].Queued
 }
 
 // TargetColourAt examines the neighbouring pixels to the point (x, y) and
@@ -159,21 +208,3 @@ func (c *PixelArray) TargetColourAt(x, y, blur int32, width, height int) Colour2
 	}
 	return Colour24{0, 0, 0}
 }
-
-// draw function for the final image
-func draw(pic *image.RGBA, name string) {
-	fmt.Println("Drawing", name)
-	file, err := os.Create(name)
-
-	if err != nil {
-		panic(err)
-	}
-	err = png.Encode(file, pic)
-	if err != nil {
-		panic(err)
-	}
-	err = file.Close()
-	if err != nil {
-		panic(err)
-	}
-}
@@ -1,7 +1,8 @@
-package main
+package pixelart
 
 import (
 	"fmt"
+	"image"
 	"math"
 )
 
@@ -36,13 +37,30 @@ func ToString(cb ColourBasis) string {
 	}
 }
 
+// ParseColourBasis is the inverse of ToString.
+func ParseColourBasis(s string) (ColourBasis, error) {
+	for cb := RGB; cb <= BRG; cb++ {
+		if ToString(cb) == s {
+			return cb, nil
+		}
+	}
+	return RGB, fmt.Errorf("unknown colour basis %q", s)
+}
+
 type Colourspace interface {
 	ColourUsed(c Colour) bool
+	InPalette(c Colour) bool
 	GetMaxColourCount() int32
 	GetColourCount() int32
+	GetRemainingColourCount() int32
 	PopColour(c Colour) Colour24
 	PrepOpt()
 	SetEchospace(value float64)
+	SearchStats() (searches, radii int64)
+	Restrict(palette image.Image)
+
+	saveState(cp *checkpoint)
+	loadState(cp *checkpoint)
 }
 
 type multiColourSpace struct {
@@ -52,7 +70,15 @@ type multiColourSpace struct {
 	echoQueue                 *ColourQueue
 	RGBCube                   [256][256][256]bool
 	count                     int32
+	released                  int32
+	unavailable               int32
 	xCounts, yCounts, zCounts [256]int32
+	// palette is a bit for each colour of the cube Restrict left
+	// available, or nil if it hasn't been restricted
+	palette []uint64
+
+	// for progress reporting
+	searches, radii int64
 }
 
 func GetColourspace(basis ColourBasis) Colourspace {
@@ -81,6 +107,17 @@ func (space *multiColourSpace) ColourUsed(c Colour) bool {
 	return false
 }
 
+// InPalette reports whether c is one of the colours Restrict left
+// available, used or not. Every colour is when there's no palette.
+func (space *multiColourSpace) InPalette(c Colour) bool {
+	if space.palette == nil {
+		return true
+	}
+	x, y, z := space.basisOrder(c.Red(), c.Green(), c.Blue())
+	i := x<<16 | y<<8 | z
+	return space.palette[i/64]&(1<<uint(i%64)) != 0
+}
+
 func (space *multiColourSpace) GetMaxColourCount() int32 {
 	return int32(256 * 256 * 256)
 }
@@ -89,6 +126,61 @@ func (space *multiColourSpace) GetColourCount() int32 {
 	return space.count
 }
 
+func (space *multiColourSpace) GetRemainingColourCount() int32 {
+	return space.GetMaxColourCount() - space.unavailable - space.count + space.released
+}
+
+// Restrict marks every colour missing from palette as used.
+func (space *multiColourSpace) Restrict(palette image.Image) {
+	for x := 0; x < 256; x++ {
+		for y := 0; y < 256; y++ {
+			for z := 0; z < 256; z++ {
+				space.RGBCube[x][y][z] = true
+			}
+		}
+	}
+
+	var available int32
+	space.palette = make([]uint64, 256*256*256/64)
+	bounds := palette.Bounds()
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			r, g, b, _ := palette.At(px, py).RGBA()
+			x, y, z := space.basisOrder(int32(r>>8), int32(g>>8), int32(b>>8))
+			if space.RGBCube[x][y][z] {
+				space.RGBCube[x][y][z] = false
+				i := x<<16 | y<<8 | z
+				space.palette[i/64] |= 1 << uint(i%64)
+				available++
+			}
+		}
+	}
+	space.unavailable = space.GetMaxColourCount() - available
+}
+
+// basisOrder arranges a colour's channels along the axes of the cube.
+func (space *multiColourSpace) basisOrder(r, g, b int32) (x, y, z int32) {
+	switch space.colourBasis {
+	case RBG:
+		return r, b, g
+	case GBR:
+		return g, b, r
+	case GRB:
+		return g, r, b
+	case BGR:
+		return b, g, r
+	case BRG:
+		return b, r, g
+	}
+	return r, g, b
+}
+
+// SearchStats returns the number of colours popped so far and the sum of the
+// radii searched to find them.
+func (space *multiColourSpace) SearchStats() (searches, radii int64) {
+	return space.searches, space.radii
+}
+
 func (space *multiColourSpace) SetEchospace(value float64) {
 	if value == 0 {
 		space.echoStartPoint = 0
@@ -98,6 +190,66 @@ func (space *multiColourSpace) SetEchospace(value float64) {
 	}
 }
 
+func (space *multiColourSpace) saveState(cp *checkpoint) {
+	cp.Cube = make([]byte, 256*256*256/8)
+	i := 0
+	for x := 0; x < 256; x++ {
+		for y := 0; y < 256; y++ {
+			for z := 0; z < 256; z++ {
+				if space.RGBCube[x][y][z] {
+					cp.Cube[i/8] |= 1 << uint(i%8)
+				}
+				i++
+			}
+		}
+	}
+
+	cp.ColourCount = space.count
+	cp.Released = space.released
+	cp.Unavailable = space.unavailable
+	cp.Optimised = space.optimised
+	cp.XCounts = space.xCounts
+	cp.YCounts = space.yCounts
+	cp.ZCounts = space.zCounts
+
+	cp.Echo = nil
+	if space.echoQueue != nil {
+		q := space.echoQueue
+		for n := 0; n < q.count; n++ {
+			c := q.nodes[(q.head+n)%len(q.nodes)]
+			cp.Echo = append(cp.Echo, c.red, c.green, c.blue)
+		}
+	}
+}
+
+// loadState expects SetEchospace to have been called with the checkpointed
+// run's echospacing, so the echo queue is sized as it was originally.
+func (space *multiColourSpace) loadState(cp *checkpoint) {
+	i := 0
+	for x := 0; x < 256; x++ {
+		for y := 0; y < 256; y++ {
+			for z := 0; z < 256; z++ {
+				space.RGBCube[x][y][z] = cp.Cube[i/8]&(1<<uint(i%8)) != 0
+				i++
+			}
+		}
+	}
+
+	space.count = cp.ColourCount
+	space.released = cp.Released
+	space.unavailable = cp.Unavailable
+	space.optimised = cp.Optimised
+	space.xCounts = cp.XCounts
+	space.yCounts = cp.YCounts
+	space.zCounts = cp.ZCounts
+
+	if space.echoQueue != nil {
+		for n := 0; n+2 < len(cp.Echo); n += 3 {
+			space.echoQueue.Push(Colour24{cp.Echo[n], cp.Echo[n+1], cp.Echo[n+2]})
+		}
+	}
+}
+
 func (space *multiColourSpace) PrepOpt() {
 	if space.echoStartPoint <= 0 {
 		for x := 0; x < 256; x++ {
@@ -215,8 +367,9 @@ func (space *multiColourSpace) basePopColour(r, g, b int32) (red, green, blue in
 				}
 			}
 		}
-
+		space.radii += int64(outer_radius)
 	}
+	space.searches++
 
 	space.RGBCube[red][green][blue] = true
 	space.count++
@@ -226,6 +379,7 @@ func (space *multiColourSpace) basePopColour(r, g, b int32) (red, green, blue in
 		if space.count >= space.echoStartPoint {
 			echo := space.echoQueue.Pop()
 			space.RGBCube[echo.red][echo.green][echo.blue] = false
+			space.released++
 		}
 
 	}
@@ -300,8 +454,9 @@ func (space *multiColourSpace) basePopColourOpt(r, g, b int32) (red, green, blue
 				}
 			}
 		}
-
+		space.radii += int64(outer_radius)
 	}
+	space.searches++
 
 	space.xCounts[red]--
 	space.yCounts[green]--
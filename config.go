@@ -0,0 +1,296 @@
+package pixelart
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings describe a run as values keyed by the names of the command line
+// flags that set them. They're what run files, presets and image metadata
+// hold.
+type Settings map[string]string
+
+// ArgsSettings writes down the settings of args that decide what the image
+// looks like.
+func ArgsSettings(args GenerateArgs) Settings {
+	s := Settings{
+		"width":        strconv.Itoa(args.Width),
+		"height":       strconv.Itoa(args.Height),
+		"colour-basis": ToString(args.ColourBasis),
+		"seed":         fmt.Sprintf("0x%02X%02X%02X", args.StartRed, args.StartGreen, args.StartBlue),
+		"seed-x":       strconv.Itoa(args.StartX),
+		"seed-y":       strconv.Itoa(args.StartY),
+		"blur":         strconv.Itoa(int(args.Blur)),
+		"chan":         strconv.Itoa(int(args.ChanSize)),
+		"cpus":         strconv.Itoa(args.CPUs),
+		"es":           strconv.FormatFloat(args.Echospace, 'g', -1, 64),
+		"flip-draw":    strconv.FormatBool(args.FlipDraw),
+		"tag":          args.Tag,
+		"rng-seed":     strconv.FormatInt(args.RNGSeed, 10),
+	}
+	if args.SeedImagePath != "" {
+		s["seed-image"] = args.SeedImagePath
+		s["seed-rr"] = strconv.FormatFloat(args.SeedRejectionRate, 'g', -1, 64)
+		s["seed-chroma-key"] = fmt.Sprintf("0x%06X", args.ChromaColour)
+		s["seed-dupes"] = strconv.FormatBool(args.ReseedDupes)
+	}
+	if args.PalettePath != "" {
+		s["palette"] = args.PalettePath
+	}
+	if len(args.Seeds) > 0 {
+		s["seeds"] = FormatSeeds(args.Seeds)
+	}
+	return s
+}
+
+// Args sets the fields of args that s has values for, in key order, and
+// ignores the keys that aren't GenerateArgs settings. The seed image and
+// palette are named in SeedImagePath and PalettePath for the caller to load.
+// Values that don't parse are all reported, as ValidationErrors; it doesn't
+// check the values that do, which is what GenerateArgs.Validate is for.
+func (s Settings) Args(args GenerateArgs) (GenerateArgs, error) {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs ValidationErrors
+	for _, key := range keys {
+		set, ok := settingSetters[key]
+		if !ok {
+			continue
+		}
+		if err := set(&args, s[key]); err != nil {
+			errs.add(key, "bad setting %q: %v", s[key], err)
+		}
+	}
+	if len(errs) > 0 {
+		return args, errs
+	}
+	return args, nil
+}
+
+// IsSetting reports whether Settings.Args understands name.
+func IsSetting(name string) bool {
+	_, ok := settingSetters[name]
+	return ok
+}
+
+func setInt(field *int, value string) (err error) {
+	*field, err = strconv.Atoi(value)
+	return
+}
+
+// parseColour reads a 24 bit colour such as 0xFF00FF.
+func parseColour(value string) (int, error) {
+	c, err := strconv.ParseInt(value, 0, 32)
+	if err == nil && (c < 0 || c > 0xFFFFFF) {
+		err = errors.New("not a 24 bit colour")
+	}
+	return int(c), err
+}
+
+var settingSetters = map[string]func(args *GenerateArgs, value string) error{
+	"width":  func(args *GenerateArgs, v string) error { return setInt(&args.Width, v) },
+	"height": func(args *GenerateArgs, v string) error { return setInt(&args.Height, v) },
+	"colour-basis": func(args *GenerateArgs, v string) (err error) {
+		args.ColourBasis, err = ParseColourBasis(strings.ToLower(v))
+		return
+	},
+	"seed": func(args *GenerateArgs, v string) error {
+		c, err := parseColour(v)
+		args.StartRed, args.StartGreen, args.StartBlue = c>>16, (c>>8)&0xFF, c&0xFF
+		return err
+	},
+	"seed-red":   func(args *GenerateArgs, v string) error { return setInt(&args.StartRed, v) },
+	"seed-green": func(args *GenerateArgs, v string) error { return setInt(&args.StartGreen, v) },
+	"seed-blue":  func(args *GenerateArgs, v string) error { return setInt(&args.StartBlue, v) },
+	"seed-x":     func(args *GenerateArgs, v string) error { return setInt(&args.StartX, v) },
+	"seed-y":     func(args *GenerateArgs, v string) error { return setInt(&args.StartY, v) },
+	"seeds": func(args *GenerateArgs, v string) (err error) {
+		args.Seeds, err = ParseSeeds(v)
+		return
+	},
+	"blur": func(args *GenerateArgs, v string) error {
+		n, err := strconv.Atoi(v)
+		args.Blur = int32(n)
+		return err
+	},
+	"chan": func(args *GenerateArgs, v string) error {
+		n, err := strconv.Atoi(v)
+		args.ChanSize = int32(n)
+		return err
+	},
+	"cpus": func(args *GenerateArgs, v string) error { return setInt(&args.CPUs, v) },
+	"es": func(args *GenerateArgs, v string) (err error) {
+		args.Echospace, err = strconv.ParseFloat(v, 64)
+		return
+	},
+	"flip-draw": func(args *GenerateArgs, v string) (err error) {
+		args.FlipDraw, err = strconv.ParseBool(v)
+		return
+	},
+	"ir": func(args *GenerateArgs, v string) (err error) {
+		args.DrawIR, err = strconv.ParseBool(v)
+		return
+	},
+	"name": func(args *GenerateArgs, v string) error { args.Name = v; return nil },
+	"tag":  func(args *GenerateArgs, v string) error { args.Tag = v; return nil },
+	"rng-seed": func(args *GenerateArgs, v string) (err error) {
+		args.RNGSeed, err = strconv.ParseInt(v, 10, 64)
+		return
+	},
+	"seed-image": func(args *GenerateArgs, v string) error { args.SeedImagePath = v; return nil },
+	"seed-rr": func(args *GenerateArgs, v string) (err error) {
+		args.SeedRejectionRate, err = strconv.ParseFloat(v, 64)
+		return
+	},
+	"seed-chroma-key": func(args *GenerateArgs, v string) (err error) {
+		args.ChromaColour, err = parseColour(v)
+		return
+	},
+	"seed-dupes": func(args *GenerateArgs, v string) (err error) {
+		args.ReseedDupes, err = strconv.ParseBool(v)
+		return
+	},
+	"palette":    func(args *GenerateArgs, v string) error { args.PalettePath = v; return nil },
+	"checkpoint": func(args *GenerateArgs, v string) error { args.CheckpointPath = v; return nil },
+	"checkpoint-interval": func(args *GenerateArgs, v string) (err error) {
+		args.CheckpointInterval, err = time.ParseDuration(v)
+		return
+	},
+	"resume": func(args *GenerateArgs, v string) error { args.ResumePath = v; return nil },
+}
+
+// ReadSettings loads a run file, in JSON, TOML or YAML by its extension.
+func ReadSettings(path string) (Settings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := ParseSettings(data, settingsFormat(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+func settingsFormat(path string) string {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yml":
+		return "yaml"
+	default:
+		return strings.TrimPrefix(ext, ".")
+	}
+}
+
+// ParseSettings reads a run file in format "json", "toml" or "yaml". The
+// file is a single table of flag names to strings, numbers or booleans.
+func ParseSettings(data []byte, format string) (Settings, error) {
+	var raw map[string]interface{}
+	var err error
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&raw)
+	case "toml":
+		err = toml.Unmarshal(data, &raw)
+	case "yaml":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unknown run file format %q, expected json, toml or yaml", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := make(Settings, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string:
+			s[key] = v
+		case bool:
+			s[key] = strconv.FormatBool(v)
+		case json.Number:
+			s[key] = v.String()
+		case int:
+			s[key] = strconv.Itoa(v)
+		case int64:
+			s[key] = strconv.FormatInt(v, 10)
+		case uint64:
+			s[key] = strconv.FormatUint(v, 10)
+		case float64:
+			s[key] = strconv.FormatFloat(v, 'g', -1, 64)
+		default:
+			return nil, fmt.Errorf("%s should be a string, number or boolean", key)
+		}
+	}
+	return s, nil
+}
+
+// WriteSettings writes s as a run file in format "json", "toml" or "yaml".
+// Every value is written as a string, which all three read back the same.
+func WriteSettings(w io.Writer, s Settings, format string) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(b, '\n'))
+		return err
+	case "toml":
+		return toml.NewEncoder(w).Encode(map[string]string(s))
+	case "yaml":
+		return yaml.NewEncoder(w).Encode(map[string]string(s))
+	}
+	return fmt.Errorf("unknown run file format %q, expected json, toml or yaml", format)
+}
+
+// presets are the named looks every front end offers.
+var presets = map[string]Settings{
+	// soft clouds that drift through the cube and come back round
+	"nebula": {"blur": "4", "chan": "64", "es": "0.02", "colour-basis": "grb"},
+	// facets grown straight out from the seed
+	"crystal": {"blur": "1", "chan": "4096", "colour-basis": "rgb"},
+	// fine streaks that keep to one hue for a long way
+	"grain": {"blur": "1", "chan": "8", "colour-basis": "bgr"},
+	// the colours of a negative, smeared out
+	"smoke": {"blur": "12", "chan": "32", "flip-draw": "true", "colour-basis": "brg"},
+}
+
+// Preset returns a copy of the named built-in preset.
+func Preset(name string) (Settings, bool) {
+	p, ok := presets[strings.ToLower(name)]
+	if !ok {
+		return nil, false
+	}
+	s := make(Settings, len(p))
+	for key, value := range p {
+		s[key] = value
+	}
+	return s, true
+}
+
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
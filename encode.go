@@ -0,0 +1,180 @@
+package pixelart
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// EncodeOptions holds the settings of the formats that have any.
+type EncodeOptions struct {
+	PNGCompression png.CompressionLevel
+	Metadata       Metadata
+}
+
+// Format is an image file format that pictures can be saved in.
+type Format struct {
+	Name string
+	// Extensions are matched against output file names, without the dot.
+	// The first one is used when composing a name.
+	Extensions []string
+	Encode     func(w io.Writer, pic image.Image, opts EncodeOptions) error
+}
+
+var formats = make(map[string]Format)
+
+// RegisterFormat makes f available to LookupFormat and FormatForFile,
+// replacing any format of the same name.
+func RegisterFormat(f Format) {
+	formats[strings.ToLower(f.Name)] = f
+}
+
+func LookupFormat(name string) (Format, error) {
+	f, ok := formats[strings.ToLower(name)]
+	if !ok {
+		return f, fmt.Errorf("unknown image format %q, expected one of %s", name, strings.Join(FormatNames(), ", "))
+	}
+	return f, nil
+}
+
+// FormatForFile picks a format by the extension of path.
+func FormatForFile(path string) (Format, bool) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if ext == "" {
+		return Format{}, false
+	}
+	for _, f := range formats {
+		for _, e := range f.Extensions {
+			if e == ext {
+				return f, true
+			}
+		}
+	}
+	return Format{}, false
+}
+
+func FormatNames() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParsePNGCompression reads one of "default", "none", "fast" or "best".
+func ParsePNGCompression(s string) (png.CompressionLevel, error) {
+	switch strings.ToLower(s) {
+	case "", "default":
+		return png.DefaultCompression, nil
+	case "none":
+		return png.NoCompression, nil
+	case "fast":
+		return png.BestSpeed, nil
+	case "best":
+		return png.BestCompression, nil
+	}
+	return png.DefaultCompression, fmt.Errorf("unknown PNG compression %q, expected one of default, none, fast, best", s)
+}
+
+func init() {
+	RegisterFormat(Format{
+		Name:       "png",
+		Extensions: []string{"png"},
+		Encode: func(w io.Writer, pic image.Image, opts EncodeOptions) error {
+			enc := png.Encoder{CompressionLevel: opts.PNGCompression}
+			if len(opts.Metadata) == 0 {
+				return enc.Encode(w, pic)
+			}
+			var buf bytes.Buffer
+			if err := enc.Encode(&buf, pic); err != nil {
+				return err
+			}
+			return encodePNGText(w, buf.Bytes(), opts.Metadata)
+		},
+	})
+	RegisterFormat(Format{
+		Name:       "tiff",
+		Extensions: []string{"tiff", "tif"},
+		Encode: func(w io.Writer, pic image.Image, opts EncodeOptions) error {
+			return tiff.Encode(w, pic, &tiff.Options{Compression: tiff.Deflate, Predictor: true})
+		},
+	})
+	RegisterFormat(Format{
+		Name:       "bmp",
+		Extensions: []string{"bmp"},
+		Encode: func(w io.Writer, pic image.Image, opts EncodeOptions) error {
+			return bmp.Encode(w, pic)
+		},
+	})
+	RegisterFormat(Format{
+		Name:       "ppm",
+		Extensions: []string{"ppm"},
+		Encode: func(w io.Writer, pic image.Image, opts EncodeOptions) error {
+			return encodePPM(w, pic)
+		},
+	})
+	RegisterFormat(Format{
+		Name:       "pam",
+		Extensions: []string{"pam"},
+		Encode: func(w io.Writer, pic image.Image, opts EncodeOptions) error {
+			return encodePAM(w, pic)
+		},
+	})
+	RegisterFormat(Format{
+		Name:       "qoi",
+		Extensions: []string{"qoi"},
+		Encode: func(w io.Writer, pic image.Image, opts EncodeOptions) error {
+			return encodeQOI(w, pic)
+		},
+	})
+}
+
+// isOpaque reports whether pic can be saved without an alpha channel.
+func isOpaque(pic image.Image) bool {
+	o, ok := pic.(interface {
+		Opaque() bool
+	})
+	return ok && o.Opaque()
+}
+
+// nrgbaRow appends the non-premultiplied RGBA bytes of row y of pic to buf.
+func nrgbaRow(buf []byte, pic image.Image, y int) []byte {
+	b := pic.Bounds()
+	if rgba, ok := pic.(*image.RGBA); ok {
+		i := rgba.PixOffset(b.Min.X, y)
+		row := rgba.Pix[i : i+4*b.Dx()]
+		for i := 0; i < len(row); i += 4 {
+			// opaque pixels are already non-premultiplied
+			a := uint32(row[i+3])
+			if a == 0 || a == 0xff {
+				buf = append(buf, row[i:i+4]...)
+				continue
+			}
+			a *= 0x101
+			r, g, bl := uint32(row[i])*0x101*0xffff/a, uint32(row[i+1])*0x101*0xffff/a, uint32(row[i+2])*0x101*0xffff/a
+			buf = append(buf, uint8(r>>8), uint8(g>>8), uint8(bl>>8), row[i+3])
+		}
+		return buf
+	}
+	if nrgba, ok := pic.(*image.NRGBA); ok {
+		i := nrgba.PixOffset(b.Min.X, y)
+		return append(buf, nrgba.Pix[i:i+4*b.Dx()]...)
+	}
+	for x := b.Min.X; x < b.Max.X; x++ {
+		r, g, bl, a := pic.At(x, y).RGBA()
+		if a != 0 && a != 0xffff {
+			r, g, bl = r*0xffff/a, g*0xffff/a, bl*0xffff/a
+		}
+		buf = append(buf, uint8(r>>8), uint8(g>>8), uint8(bl>>8), uint8(a>>8))
+	}
+	return buf
+}
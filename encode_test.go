@@ -0,0 +1,298 @@
+package pixelart
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// encodeTestImage has stretches of each kind of pixel QOI has an op for:
+// long runs, small and larger steps from the previous pixel, colours seen
+// before and ones that are new. With alpha its pixels are part transparent
+// too.
+func encodeTestImage(alpha bool) *image.NRGBA {
+	rng := rand.New(rand.NewSource(1))
+	pic := image.NewNRGBA(image.Rect(0, 0, 100, 60))
+	c := color.NRGBA{10, 20, 30, 0xFF}
+	var seen []color.NRGBA
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 100; x++ {
+			switch k := (x / 10) % 5; {
+			case y < 2:
+				// a run longer than one op can hold
+			case k == 0:
+				c.R += uint8(rng.Intn(4)) - 2
+				c.G += uint8(rng.Intn(4)) - 2
+				c.B += uint8(rng.Intn(4)) - 2
+			case k == 1:
+				dg := uint8(rng.Intn(64)) - 32
+				c.R += dg + uint8(rng.Intn(16)) - 8
+				c.G += dg
+				c.B += dg + uint8(rng.Intn(16)) - 8
+			case k == 2 && len(seen) > 0:
+				c = seen[rng.Intn(len(seen))]
+			default:
+				c.R, c.G, c.B = uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256))
+			}
+			if alpha && rng.Intn(3) == 0 {
+				c.A = uint8(rng.Intn(256))
+			}
+			seen = append(seen, c)
+			pic.SetNRGBA(x, y, c)
+		}
+	}
+	return pic
+}
+
+// opaqueTestImage is encodeTestImage without alpha as an *image.RGBA, and
+// set away from the origin, so it's written without an alpha channel and
+// from its bounds.
+func opaqueTestImage() *image.RGBA {
+	src := encodeTestImage(false)
+	b := src.Bounds().Add(image.Pt(5, -3))
+	pic := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := src.NRGBAAt(x-5, y+3)
+			pic.SetRGBA(x, y, color.RGBA{c.R, c.G, c.B, c.A})
+		}
+	}
+	return pic
+}
+
+// sameNRGBA compares got, from the origin, with want over want's bounds,
+// ignoring alpha if alpha is false.
+func sameNRGBA(t *testing.T, got *image.NRGBA, want image.Image, alpha bool) {
+	t.Helper()
+	b := want.Bounds()
+	if got.Bounds().Size() != b.Size() {
+		t.Fatalf("decoded %v, want %v", got.Bounds().Size(), b.Size())
+	}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			g := got.NRGBAAt(x, y)
+			w := color.NRGBAModel.Convert(want.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			if !alpha {
+				w.A = 0xFF
+			}
+			if g != w {
+				t.Fatalf("pixel %d,%d is %v, want %v", x, y, g, w)
+			}
+		}
+	}
+}
+
+// decodeQOI reads what encodeQOI writes, after the specification.
+func decodeQOI(r io.Reader) (pic *image.NRGBA, channels byte, err error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, 14)
+	if _, err = io.ReadFull(br, header); err != nil {
+		return nil, 0, err
+	}
+	if string(header[:4]) != "qoif" {
+		return nil, 0, fmt.Errorf("bad magic %q", header[:4])
+	}
+	w, h := int(binary.BigEndian.Uint32(header[4:])), int(binary.BigEndian.Uint32(header[8:]))
+	channels = header[12]
+	pic = image.NewNRGBA(image.Rect(0, 0, w, h))
+
+	var index [64][4]byte
+	px := [4]byte{0, 0, 0, 255}
+	run := 0
+	for i := 0; i < len(pic.Pix); i += 4 {
+		if run > 0 {
+			run--
+		} else {
+			op, err := br.ReadByte()
+			if err != nil {
+				return nil, 0, err
+			}
+			switch {
+			case op == qoiOpRGB:
+				_, err = io.ReadFull(br, px[:3])
+			case op == qoiOpRGBA:
+				_, err = io.ReadFull(br, px[:])
+			case op&0xc0 == qoiOpIndex:
+				px = index[op&0x3f]
+			case op&0xc0 == qoiOpDiff:
+				px[0] += (op>>4)&3 - 2
+				px[1] += (op>>2)&3 - 2
+				px[2] += op&3 - 2
+			case op&0xc0 == qoiOpLuma:
+				var b2 byte
+				if b2, err = br.ReadByte(); err != nil {
+					break
+				}
+				dg := op&0x3f - 32
+				px[0] += dg + b2>>4 - 8
+				px[1] += dg
+				px[2] += dg + b2&0xf - 8
+			case op&0xc0 == qoiOpRun:
+				run = int(op & 0x3f)
+			}
+			if err != nil {
+				return nil, 0, err
+			}
+			index[(int(px[0])*3+int(px[1])*5+int(px[2])*7+int(px[3])*11)%64] = px
+		}
+		copy(pic.Pix[i:i+4], px[:])
+	}
+
+	end := make([]byte, 8)
+	if _, err = io.ReadFull(br, end); err != nil {
+		return nil, 0, err
+	}
+	if !bytes.Equal(end, []byte{0, 0, 0, 0, 0, 0, 0, 1}) {
+		return nil, 0, fmt.Errorf("bad end marker %v", end)
+	}
+	if _, err = br.ReadByte(); err != io.EOF {
+		return nil, 0, fmt.Errorf("data after the end marker")
+	}
+	return pic, channels, nil
+}
+
+func TestQOIRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		pic      image.Image
+		channels byte
+	}{
+		{"alpha", encodeTestImage(true), 4},
+		{"opaque", opaqueTestImage(), 3},
+	} {
+		var buf bytes.Buffer
+		if err := encodeQOI(&buf, tc.pic); err != nil {
+			t.Fatal(err)
+		}
+		got, channels, err := decodeQOI(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if channels != tc.channels {
+			t.Errorf("%s: %d channels, want %d", tc.name, channels, tc.channels)
+		}
+		sameNRGBA(t, got, tc.pic, true)
+	}
+}
+
+// decodeNetpbm reads the P6 and P7 files encodePPM and encodePAM write.
+// The header fields are read as they come, which the encoders' headers
+// allow.
+func decodeNetpbm(r io.Reader) (pic *image.NRGBA, depth int, err error) {
+	br := bufio.NewReader(r)
+	magic, err := br.ReadString('\n')
+	if err != nil {
+		return nil, 0, err
+	}
+	var w, h, maxval int
+	switch magic {
+	case "P6\n":
+		depth = 3
+		if _, err = fmt.Fscanf(br, "%d %d\n%d\n", &w, &h, &maxval); err != nil {
+			return nil, 0, err
+		}
+	case "P7\n":
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return nil, 0, err
+			}
+			if line == "ENDHDR\n" {
+				break
+			}
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				return nil, 0, fmt.Errorf("bad header line %q", line)
+			}
+			var n int
+			fmt.Sscan(fields[1], &n)
+			switch fields[0] {
+			case "WIDTH":
+				w = n
+			case "HEIGHT":
+				h = n
+			case "DEPTH":
+				depth = n
+			case "MAXVAL":
+				maxval = n
+			}
+		}
+	default:
+		return nil, 0, fmt.Errorf("bad magic %q", magic)
+	}
+	if maxval != 255 {
+		return nil, 0, fmt.Errorf("maxval %d, want 255", maxval)
+	}
+
+	pic = image.NewNRGBA(image.Rect(0, 0, w, h))
+	px := make([]byte, depth)
+	for i := 0; i < len(pic.Pix); i += 4 {
+		if _, err = io.ReadFull(br, px); err != nil {
+			return nil, 0, err
+		}
+		pic.Pix[i+3] = 0xFF
+		copy(pic.Pix[i:i+4], px)
+	}
+	if _, err = br.ReadByte(); err != io.EOF {
+		return nil, 0, fmt.Errorf("data after the pixels")
+	}
+	return pic, depth, nil
+}
+
+func TestNetpbmRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		encode func(io.Writer, image.Image) error
+		pic    image.Image
+		depth  int
+		alpha  bool
+	}{
+		{"ppm", encodePPM, encodeTestImage(true), 3, false},
+		{"pam alpha", encodePAM, encodeTestImage(true), 4, true},
+		{"pam opaque", encodePAM, opaqueTestImage(), 3, true},
+	} {
+		var buf bytes.Buffer
+		if err := tc.encode(&buf, tc.pic); err != nil {
+			t.Fatal(err)
+		}
+		got, depth, err := decodeNetpbm(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if depth != tc.depth {
+			t.Errorf("%s: depth %d, want %d", tc.name, depth, tc.depth)
+		}
+		sameNRGBA(t, got, tc.pic, tc.alpha)
+	}
+}
+
+func TestFormatForFile(t *testing.T) {
+	for _, tc := range []struct {
+		path, format string
+	}{
+		{"out.png", "png"},
+		{"dir.d/OUT.PNG", "png"},
+		{"out.tif", "tiff"},
+		{"out.tiff", "tiff"},
+		{"out.bmp", "bmp"},
+		{"out.ppm", "ppm"},
+		{"out.pam", "pam"},
+		{"out.final.qoi", "qoi"},
+		{"out", ""},
+		{"out.", ""},
+		{"dir.png/out", ""},
+		{"out.jpeg", ""},
+	} {
+		f, ok := FormatForFile(tc.path)
+		if ok != (tc.format != "") || f.Name != tc.format {
+			t.Errorf("FormatForFile(%q) = %q, %v, want %q", tc.path, f.Name, ok, tc.format)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package pixelart
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+// NotFilled marks pixels a run never reached in a FillOrder.
+const NotFilled = ^uint32(0)
+
+// FillOrder records when each pixel of a run was filled. Set
+// GenerateArgs.FillOrder to have Generate record one.
+type FillOrder struct {
+	Width  int
+	Height int
+	// Seeds is how many of the first pixels were seeds rather than fills.
+	Seeds int
+	// Index holds the position in the fill of each pixel, row by row.
+	Index []uint32
+
+	// Distances asks for Distance to be recorded as well: how far, row by
+	// row, the colour each pixel got was from the colour it wanted. It's
+	// NaN for the seeds, the pixels never filled and, in a resumed run,
+	// the ones filled before the checkpoint.
+	Distances bool
+	Distance  []float32
+}
+
+func (order *FillOrder) reset(width, height int) {
+	order.Width, order.Height, order.Seeds = width, height, 0
+	order.Index = make([]uint32, width*height)
+	for i := range order.Index {
+		order.Index[i] = NotFilled
+	}
+	order.Distance = nil
+	if order.Distances {
+		order.Distance = make([]float32, width*height)
+		for i := range order.Distance {
+			order.Distance[i] = float32(math.NaN())
+		}
+	}
+}
+
+func (order *FillOrder) set(x, y int, index int32) {
+	order.Index[y*order.Width+x] = uint32(index)
+}
+
+func (order *FillOrder) At(x, y int) uint32 {
+	return order.Index[y*order.Width+x]
+}
+
+func (order *FillOrder) setDistance(x, y int, target, chosen Colour24) {
+	if order.Distance != nil {
+		order.Distance[y*order.Width+x] = float32(targetDistance(target, chosen))
+	}
+}
+
+// DistanceAt is the distance recorded for the pixel, if there is one.
+func (order *FillOrder) DistanceAt(x, y int) (float64, bool) {
+	if order.Distance == nil {
+		return 0, false
+	}
+	d := float64(order.Distance[y*order.Width+x])
+	return d, !math.IsNaN(d)
+}
+
+// Filled counts the pixels that were filled.
+func (order *FillOrder) Filled() (n int) {
+	for _, i := range order.Index {
+		if i != NotFilled {
+			n++
+		}
+	}
+	return
+}
+
+// Points lists the pixels in the order they were filled. It's an error if
+// the indices don't run from 0 without a gap, as for the order of a run
+// resumed from a checkpoint that didn't record one.
+func (order *FillOrder) Points() ([]image.Point, error) {
+	pts := make([]image.Point, order.Filled())
+	seen := make([]bool, len(pts))
+	for i, index := range order.Index {
+		if index == NotFilled {
+			continue
+		}
+		if int(index) >= len(pts) || seen[index] {
+			return nil, fmt.Errorf("fill order has a gap or repeat: pixel %d,%d is number %d of %d", i%order.Width, i/order.Width, index, len(pts))
+		}
+		seen[index] = true
+		pts[index] = image.Pt(i%order.Width, i/order.Width)
+	}
+	return pts, nil
+}
+
+// Render draws the pixels of final that were filled before the filled'th,
+// leaving the rest black as they would have been at that point of the run.
+func (order *FillOrder) Render(final image.Image, filled int) *image.RGBA {
+	r := image.Rect(0, 0, order.Width, order.Height)
+	src, ok := final.(*image.RGBA)
+	if !ok || src.Bounds() != r {
+		src = image.NewRGBA(r)
+		draw.Draw(src, r, final, final.Bounds().Min, draw.Src)
+	}
+
+	pic := image.NewRGBA(r)
+	for i, index := range order.Index {
+		if index != NotFilled && int(index) < filled {
+			copy(pic.Pix[4*i:4*i+4], src.Pix[4*i:4*i+4])
+		} else {
+			pic.Pix[4*i+3] = FullAlpha
+		}
+	}
+	return pic
+}
+
+// Image packs the order into the colour channels of an image. With 24 bits
+// the index is stored as red, green, blue; with 32 it's red, green, blue,
+// alpha, most significant byte first. Unfilled pixels have every bit set.
+// A 24 bit image of 1<<24 pixels needs all of them filled, as an unfilled
+// one would read back as the last.
+func (order *FillOrder) Image(bits int) (image.Image, error) {
+	r := image.Rect(0, 0, order.Width, order.Height)
+	switch bits {
+	case 24:
+		if n := order.Width * order.Height; n > 1<<24 {
+			return nil, errors.New("fill order is too long for a 24 bit image")
+		} else if n == 1<<24 && order.Filled() < n {
+			return nil, errors.New("an unfinished fill order this big can't be told apart from a finished one in a 24 bit image; use 32 bits")
+		}
+		pic := image.NewRGBA(r)
+		for i, index := range order.Index {
+			pic.Pix[4*i] = uint8(index >> 16)
+			pic.Pix[4*i+1] = uint8(index >> 8)
+			pic.Pix[4*i+2] = uint8(index)
+			pic.Pix[4*i+3] = FullAlpha
+		}
+		return pic, nil
+	case 32:
+		pic := image.NewNRGBA(r)
+		for i, index := range order.Index {
+			binary.BigEndian.PutUint32(pic.Pix[4*i:], index)
+		}
+		return pic, nil
+	}
+	return nil, fmt.Errorf("fill order images are 24 or 32 bit, not %d", bits)
+}
+
+// fillOrderSeedsKey is the metadata keyword of an order image's seed count,
+// which the pixels can't hold.
+const fillOrderSeedsKey = "pixelart fill order seeds"
+
+// Metadata records what Image leaves out, for an encoder to save with it.
+func (order *FillOrder) Metadata() Metadata {
+	return Metadata{"Software": "pixelart", fillOrderSeedsKey: strconv.Itoa(order.Seeds)}
+}
+
+// FillOrderSeeds is the seed count saved from FillOrder.Metadata, if meta
+// has one.
+func (meta Metadata) FillOrderSeeds() (int, bool) {
+	n, err := strconv.Atoi(meta[fillOrderSeedsKey])
+	return n, err == nil && n >= 0
+}
+
+// FillOrderFromImage reads an order packed by FillOrder.Image, of a run
+// that had seeds seeds.
+func FillOrderFromImage(pic image.Image, bits, seeds int) (*FillOrder, error) {
+	b := pic.Bounds()
+	order := new(FillOrder)
+	order.reset(b.Dx(), b.Dy())
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			var index uint32
+			switch bits {
+			case 24:
+				c := color.RGBAModel.Convert(pic.At(b.Min.X+x, b.Min.Y+y)).(color.RGBA)
+				index = uint32(c.R)<<16 | uint32(c.G)<<8 | uint32(c.B)
+				if index == 1<<24-1 && b.Dx()*b.Dy() < 1<<24 {
+					index = NotFilled
+				}
+			case 32:
+				c := color.NRGBAModel.Convert(pic.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+				index = uint32(c.R)<<24 | uint32(c.G)<<16 | uint32(c.B)<<8 | uint32(c.A)
+			default:
+				return nil, fmt.Errorf("fill order images are 24 or 32 bit, not %d", bits)
+			}
+			order.Index[y*order.Width+x] = index
+		}
+	}
+	if seeds < 0 {
+		return nil, fmt.Errorf("fill order seed count %d is negative", seeds)
+	} else if filled := order.Filled(); seeds > filled {
+		return nil, fmt.Errorf("fill order image has %d pixels filled, fewer than its %d seeds", filled, seeds)
+	}
+	order.Seeds = seeds
+	return order, nil
+}
+
+var fillOrderMagic = []byte("PXFO")
+
+// WriteTo saves the order as "PXFO" followed by the width, height, seed
+// count and every index as little endian 32 bit words.
+func (order *FillOrder) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	bw.Write(fillOrderMagic)
+	for _, v := range []uint32{uint32(order.Width), uint32(order.Height), uint32(order.Seeds)} {
+		binary.Write(bw, binary.LittleEndian, v)
+	}
+	if err := binary.Write(bw, binary.LittleEndian, order.Index); err != nil {
+		return 0, err
+	}
+	return int64(16 + 4*len(order.Index)), bw.Flush()
+}
+
+// ReadFillOrder reads an order saved by FillOrder.WriteTo.
+func ReadFillOrder(r io.Reader) (*FillOrder, error) {
+	br := bufio.NewReader(r)
+	head := make([]byte, 16)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, err
+	}
+	if string(head[:4]) != string(fillOrderMagic) {
+		return nil, errors.New("not a fill order file")
+	}
+	order := &FillOrder{
+		Width:  int(binary.LittleEndian.Uint32(head[4:])),
+		Height: int(binary.LittleEndian.Uint32(head[8:])),
+		Seeds:  int(binary.LittleEndian.Uint32(head[12:])),
+	}
+	if order.Width > MaxWidth || order.Height > MaxHeight {
+		return nil, fmt.Errorf("fill order of %dx%d is too big", order.Width, order.Height)
+	}
+	order.Index = make([]uint32, order.Width*order.Height)
+	if err := binary.Read(br, binary.LittleEndian, order.Index); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
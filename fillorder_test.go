@@ -0,0 +1,98 @@
+package pixelart
+
+import (
+	"bytes"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+// testFillOrder fills all but a few pixels of a width x height order in a
+// shuffled order, the first seeds of them being seeds.
+func testFillOrder(width, height, seeds int) *FillOrder {
+	order := new(FillOrder)
+	order.reset(width, height)
+	rng := rand.New(rand.NewSource(1))
+	for n, i := range rng.Perm(width * height)[width:] {
+		order.Index[i] = uint32(n)
+	}
+	order.Seeds = seeds
+	return order
+}
+
+func sameFillOrder(t *testing.T, got, want *FillOrder) {
+	t.Helper()
+	if got.Width != want.Width || got.Height != want.Height || got.Seeds != want.Seeds {
+		t.Fatalf("got a %dx%d order of %d seeds, want %dx%d of %d",
+			got.Width, got.Height, got.Seeds, want.Width, want.Height, want.Seeds)
+	}
+	for i := range want.Index {
+		if got.Index[i] != want.Index[i] {
+			t.Fatalf("pixel %d,%d is number %d, want %d", i%want.Width, i/want.Width, got.Index[i], want.Index[i])
+		}
+	}
+}
+
+func TestFillOrderFileRoundTrip(t *testing.T) {
+	want := testFillOrder(37, 23, 5)
+	var buf bytes.Buffer
+	n, err := want.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo says it wrote %d bytes, but wrote %d", n, buf.Len())
+	}
+	got, err := ReadFillOrder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameFillOrder(t, got, want)
+
+	if _, err = ReadFillOrder(bytes.NewReader([]byte("PNG\x00 not an order"))); err == nil {
+		t.Error("read a fill order from a file without the magic")
+	}
+}
+
+func TestFillOrderImageRoundTrip(t *testing.T) {
+	want := testFillOrder(37, 23, 5)
+	for _, bits := range []int{24, 32} {
+		pic, err := want.Image(bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// through a PNG, as the order is saved
+		var buf bytes.Buffer
+		if err = png.Encode(&buf, pic); err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := FillOrderFromImage(decoded, bits, want.Seeds)
+		if err != nil {
+			t.Fatalf("%d bits: %v", bits, err)
+		}
+		sameFillOrder(t, got, want)
+	}
+
+	if _, err := want.Image(16); err == nil {
+		t.Error("made a 16 bit order image")
+	}
+}
+
+func TestFillOrderImage24BitLimit(t *testing.T) {
+	// an unfilled pixel would be taken for the last one filled
+	order := new(FillOrder)
+	order.reset(4096, 4096)
+	for i := range order.Index[1:] {
+		order.Index[1+i] = uint32(i)
+	}
+	if _, err := order.Image(24); err == nil {
+		t.Error("made a 24 bit image of an unfinished 4096x4096 order")
+	}
+	if _, err := order.Image(32); err != nil {
+		t.Errorf("32 bits: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package pixelart
+
+import (
+	"image"
+	"math/rand"
+)
+
+// splitmix is a math/rand source whose entire state is a single word, so a
+// run's RNG can be written to a checkpoint and restored exactly.
+type splitmix struct {
+	state uint64
+}
+
+func (s *splitmix) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+func (s *splitmix) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *splitmix) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// frontier is the queue of points waiting to be filled in a deterministic
+// run. Instead of letting goroutine scheduling shuffle the points that sit
+// in the fill channel, the next point is drawn by the seeded RNG from a
+// window at the head of the queue as wide as the channel would have been.
+type frontier struct {
+	points []image.Point
+	head   int
+	window int
+	src    *splitmix
+	rng    *rand.Rand
+}
+
+func newFrontier(window int, src *splitmix) *frontier {
+	if window < 1 {
+		window = 1
+	}
+	return &frontier{window: window, src: src, rng: rand.New(src)}
+}
+
+func (f *frontier) Len() int {
+	return len(f.points) - f.head
+}
+
+func (f *frontier) Push(pt image.Point) {
+	f.points = append(f.points, pt)
+}
+
+func (f *frontier) Pop() image.Point {
+	n := minint(f.window, f.Len())
+	i := f.head + f.rng.Intn(n)
+	f.points[f.head], f.points[i] = f.points[i], f.points[f.head]
+	pt := f.points[f.head]
+	f.head++
+
+	// reclaim the consumed part of the slice once it dominates
+	if f.head > 4096 && f.head*2 > len(f.points) {
+		f.points = append(f.points[:0], f.points[f.head:]...)
+		f.head = 0
+	}
+	return pt
+}
+
+// Points returns the queued points in order, head first.
+func (f *frontier) Points() []image.Point {
+	return f.points[f.head:]
+}
@@ -1,6 +1,11 @@
-package main
+// Package pixelart paints pictures that use each colour of the 24 bit RGB
+// cube at most once. Starting from one or more seed pixels, every pixel is
+// given the unused colour closest to the average of its filled neighbours.
+package pixelart
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"image"
 	"math/rand"
@@ -53,54 +58,126 @@ func minint(a, b int) int {
 
 ///// the rest of the code and whatnot
 
-func fillPixelArray(pArray *PixelArray, cspace Colourspace, seedCh chan SeedPixel, ch chan image.Point, args GenerateArgs) (count int32) {
-	// for printing intermediate images
-	origPicName := args.name
+func fillPixelArray(ctx context.Context, pArray *PixelArray, cspace Colourspace, seedCh chan SeedPixel, ch chan image.Point, front *frontier, resume *checkpoint, args GenerateArgs) (count int32, err error) {
 	var ir_tag int32 = 1
-	var pic_fraction int32 = int32(args.width*args.height) / args.update_freq
 	var tmp_colour Colour24
-	var time_format = "15:04:05"
 
-	var seeds int32 = 0
-	//put in the seed pixels
-	rand.Seed(time.Now().UnixNano())
-	for {
-		sp, more := <-seedCh
-		if more {
-			// seed pixel from channel
-
-			if !cspace.ColourUsed(sp) || args.reseed_dupes {
-				seeds++
-				tmp_colour = cspace.PopColour(sp)
-
-				pArray.Set(int32(sp.Pt.X), int32(sp.Pt.Y), tmp_colour)
-
-				go func() {
-					for x_offset := -1; x_offset < 2; x_offset++ {
-						if sp.Pt.X+x_offset < args.width && sp.Pt.X+x_offset >= 0 {
-							for y_offset := -1; y_offset < 2; y_offset++ {
-								if sp.Pt.Y+y_offset < args.height && sp.Pt.Y+y_offset >= 0 && !(x_offset == 0 && y_offset == 0) {
-									pt := image.Pt(sp.Pt.X+x_offset, sp.Pt.Y+y_offset)
-									if !pArray.QueuedAt(int32(pt.X), int32(pt.Y)) && !pArray.FilledAt(int32(pt.X), int32(pt.Y)) {
-										pArray[pt.X][pt.Y].Queued = true
-										ch <- pt
-									}
+	var checkpoints *checkpointer
+	if args.CheckpointPath != "" {
+		checkpoints = &checkpointer{path: args.CheckpointPath, interval: args.CheckpointInterval, last: time.Now(), logf: args.logf}
+	}
+
+	// queue up the unfilled neighbours of a freshly filled point; a
+	// deterministic run keeps them in the frontier, otherwise they're sent
+	// down the fill channel from a goroutine of their own
+	queueNeighbours := func(point image.Point) {
+		for x_offset := -1; x_offset < 2; x_offset++ {
+			if point.X+x_offset < args.Width && point.X+x_offset >= 0 {
+				for y_offset := -1; y_offset < 2; y_offset++ {
+					if point.Y+y_offset < args.Height && point.Y+y_offset >= 0 && !(x_offset == 0 && y_offset == 0) {
+						pt := image.Pt(point.X+x_offset, point.Y+y_offset)
+						if !pArray.QueuedAt(int32(pt.X), int32(pt.Y)) && !pArray.FilledAt(int32(pt.X), int32(pt.Y)) {
+							(*pArray)[pt.X][pt.Y].Queued = true
+							if front != nil {
+								front.Push(pt)
+							} else {
+								select {
+								case ch <- pt:
+								case <-ctx.Done():
+									return
 								}
 							}
 						}
 					}
-				}()
+				}
 			}
+		}
+	}
 
-		} else {
-			// all seeds have been recieved
-			fmt.Println(seeds, "seeded pixels")
-			break
+	var seeds int32 = 0
+	if resume != nil {
+		// the seeds went in before the checkpoint was taken
+		count = resume.Count
+		ir_tag = resume.IRTag
+		args.logf("Resuming at %2.1f%% of pixels filled", float64(count*100)/float64(args.Width*args.Height))
+	} else {
+		//put in the seed pixels
+		for {
+			sp, more := <-seedCh
+			if more {
+				// seed pixel from channel
+
+				// colours outside the palette count as used, but the seed
+				// still goes in as the nearest one that isn't; a colour
+				// that's really been used only does if dupes are reseeded
+				if !cspace.ColourUsed(sp) || args.ReseedDupes || !cspace.InPalette(sp) {
+					seeds++
+					tmp_colour = cspace.PopColour(sp)
+
+					pArray.Set(int32(sp.Pt.X), int32(sp.Pt.Y), tmp_colour)
+					if args.Preview != nil {
+						args.Preview.set(sp.Pt.X, sp.Pt.Y, tmp_colour)
+					}
+					if args.FillOrder != nil {
+						args.FillOrder.set(sp.Pt.X, sp.Pt.Y, seeds-1)
+					}
+
+					if front != nil {
+						queueNeighbours(sp.Pt)
+					} else {
+						go queueNeighbours(sp.Pt)
+					}
+				}
+
+			} else {
+				// all seeds have been recieved
+				args.logf("%d seeded pixels", seeds)
+				break
+			}
+		}
+		// with nothing to grow from the fill would wait forever
+		if seeds == 0 {
+			if args.SeedImage != nil {
+				return 0, errors.New("seed image produced no seeds (all chroma or all rejected)")
+			}
+			return 0, errors.New("no seeds were placed")
+		}
+		count = seeds
+		if args.FillOrder != nil {
+			args.FillOrder.Seeds = int(seeds)
 		}
 	}
 
-	for count = seeds; count < int32(args.width*args.height); count++ {
-		point := <-ch
+	report := newReporter(args, count, ir_tag, cspace)
+
+	for ; count < int32(args.Width*args.Height); count++ {
+		if count%4096 == 0 {
+			if err = args.Pause.wait(ctx); err != nil {
+				return count, err
+			}
+			if ctx.Err() != nil {
+				return count, ctx.Err()
+			}
+		}
+
+		if checkpoints.Due(count) {
+			checkpoints.Save(newCheckpoint(args, count, report.tag, pArray, cspace, front))
+		}
+
+		var point image.Point
+		if front != nil {
+			if front.Len() == 0 {
+				args.logf("Nothing left to fill from, stopping early")
+				break
+			}
+			point = front.Pop()
+		} else {
+			select {
+			case point = <-ch:
+			case <-ctx.Done():
+				return count, ctx.Err()
+			}
+		}
 
 		//in the case of a point being re-queued after being filled
 		//TODO: assert !FilledAt
@@ -109,77 +186,61 @@ func fillPixelArray(pArray *PixelArray, cspace Colourspace, seedCh chan SeedPixe
 			continue
 		}
 
-		tmp_colour = pArray.TargetColourAt(int32(point.X), int32(point.Y), args.blur, args.width, args.height)
+		target := pArray.TargetColourAt(int32(point.X), int32(point.Y), args.Blur, args.Width, args.Height)
 
-		tmp_colour = cspace.PopColour(tmp_colour)
+		tmp_colour = cspace.PopColour(target)
+		if args.FillStats != nil {
+			args.FillStats.add(target, tmp_colour)
+		}
 
 		// it's nice to know the algorithm is running
-		if count > ir_tag*pic_fraction && ir_tag < args.update_freq {
-			fmt.Printf("[%s] %2.1f%% of pixels filled\n", time.Now().Format(time_format), float64(count * 100)/float64(args.width * args.height))
-			if args.draw_ir {
-				name := fmt.Sprintf("%s.%3d.png", args.tag, ir_tag)
-				go draw(pArray.ImageNRGBA(args.width, args.height, args.flip_draw), name)
-			}
-			ir_tag++
-
-			if args.update != nil {
-				go args.update(pArray.ImageNRGBA(args.width, args.height, args.flip_draw))
-			}
-
-		}
+		report.Tick(count, pArray, cspace)
 
 		if count == MaxWidth*MaxHeight*15/16 {
-			fmt.Println("Endgame optimisation... (this last one takes the longest :( )")
+			args.logf("Endgame optimisation... (this last one takes the longest :( )")
 			cspace.PrepOpt()
 		}
 
 		pArray.Set(int32(point.X), int32(point.Y), tmp_colour)
+		if args.Preview != nil {
+			args.Preview.set(point.X, point.Y, tmp_colour)
+		}
+		if args.FillOrder != nil {
+			args.FillOrder.set(point.X, point.Y, count)
+			args.FillOrder.setDistance(point.X, point.Y, target, tmp_colour)
+		}
 
-		go func() {
-			for x_offset := -1; x_offset < 2; x_offset++ {
-				if point.X+x_offset < args.width && point.X+x_offset >= 0 {
-					for y_offset := -1; y_offset < 2; y_offset++ {
-						if point.Y+y_offset < args.height && point.Y+y_offset >= 0 && !(x_offset == 0 && y_offset == 0) {
-							pt := image.Pt(point.X+x_offset, point.Y+y_offset)
-							if !pArray.QueuedAt(int32(pt.X), int32(pt.Y)) && !pArray.FilledAt(int32(pt.X), int32(pt.Y)) {
-								pArray[pt.X][pt.Y].Queued = true
-								ch <- pt
-							}
-						}
-					}
-				}
-			}
-		}()
-	}
-
-	args.name = origPicName
+		if front != nil {
+			queueNeighbours(point)
+		} else {
+			go queueNeighbours(point)
+		}
 
-	if args.update != nil {
-		go args.update(pArray.ImageNRGBA(args.width, args.height, args.flip_draw))
+		callFrameHooks(args, pArray, int(count)+1, false)
 	}
 
+	report.Done(count, pArray, cspace)
 	return
 }
 
-func processSeedImage(seedCh chan SeedPixel, args GenerateArgs) {
-	bounds := args.seed_image.Bounds()
+func processSeedImage(seedCh chan SeedPixel, rng *rand.Rand, args GenerateArgs) {
+	bounds := args.SeedImage.Bounds()
 	// spiral seeding
 
 	checkAndSeed := func(x, y int) { // if pixel != chroma, add to seed queue
 		var pixel SeedPixel
-		r, g, b, _ := args.seed_image.At(x, y).RGBA()
-		if (r/256<<16)|(g/256<<8)|b/256 != uint32(args.chroma_colour) {
-			// randomly cull a set percentage to get more balanced images
-			if args.seed_rejection_rate > 0 {
-				if rand.Float64() > args.seed_rejection_rate {
-					if args.flip_draw {
-						pixel = NewSeedPixel(uint8(255-r), uint8(255-g), uint8(255-b), x, y)
-					} else {
-						pixel = NewSeedPixel(uint8(r), uint8(g), uint8(b), x, y)
-					}
-
-					seedCh <- pixel
+		r, g, b, _ := args.SeedImage.At(x, y).RGBA()
+		if (r/256<<16)|(g/256<<8)|b/256 != uint32(args.ChromaColour) {
+			// randomly cull a set percentage to get more balanced images;
+			// 0 keeps them all
+			if args.SeedRejectionRate <= 0 || rng.Float64() > args.SeedRejectionRate {
+				if args.FlipDraw {
+					pixel = NewSeedPixel(uint8(255-r), uint8(255-g), uint8(255-b), x, y)
+				} else {
+					pixel = NewSeedPixel(uint8(r), uint8(g), uint8(b), x, y)
 				}
+
+				seedCh <- pixel
 			}
 
 		}
@@ -213,7 +274,7 @@ func processSeedImage(seedCh chan SeedPixel, args GenerateArgs) {
 	}
 
 	// seed first pixel
-	checkAndSeed(args.start_x, args.start_y)
+	checkAndSeed(args.StartX, args.StartY)
 
 	var x, y int
 	var layer int = 0
@@ -225,148 +286,339 @@ func processSeedImage(seedCh chan SeedPixel, args GenerateArgs) {
 		layer++
 
 		// topright to topleft
-		y = args.start_y - layer
+		y = args.StartY - layer
 		if y < bounds.Min.Y {
 			yMinCap = true
 		} else {
-			start = minint(args.start_x+layer, bounds.Max.X-1)
-			end = maxint(args.start_x-layer, bounds.Min.X)
+			start = minint(args.StartX+layer, bounds.Max.X-1)
+			end = maxint(args.StartX-layer, bounds.Min.X)
 			scanLine(start, end, y, y)
 		}
 
 		//topleft to botleft
-		x = args.start_x - layer
+		x = args.StartX - layer
 		if x < bounds.Min.X {
 			xMinCap = true
 		} else {
-			start = maxint(args.start_y-layer, bounds.Min.Y)
-			end = minint(args.start_y+layer, bounds.Max.Y-1)
+			start = maxint(args.StartY-layer, bounds.Min.Y)
+			end = minint(args.StartY+layer, bounds.Max.Y-1)
 			scanLine(x, x, start, end)
 		}
 
 		// botleft to botright
-		y = args.start_y + layer
+		y = args.StartY + layer
 		if y >= bounds.Max.Y {
 			yMaxCap = true
 		} else {
-			start = maxint(args.start_x-layer, bounds.Min.X)
-			end = minint(args.start_x+layer, bounds.Max.X-1)
+			start = maxint(args.StartX-layer, bounds.Min.X)
+			end = minint(args.StartX+layer, bounds.Max.X-1)
 			scanLine(start, end, y, y)
 		}
 
 		//botright to topright
-		x = args.start_x + layer
+		x = args.StartX + layer
 		if x >= bounds.Max.X {
 			xMaxCap = true
 		} else {
-			start = minint(args.start_y+layer, bounds.Max.Y-1)
-			end = maxint(args.start_y-layer, 0)
+			start = minint(args.StartY+layer, bounds.Max.Y-1)
+			end = maxint(args.StartY-layer, 0)
 			scanLine(x, x, start, end)
 		}
 	}
+	for _, sp := range args.Seeds {
+		seedCh <- sp
+	}
 	close(seedCh)
 }
 
-func composeImageName(args GenerateArgs) (name string) {
-	name = fmt.Sprintf("%s.%s", args.tag, ToString(args.colour_basis))
+// ComposeImageName builds a file name for the finished image out of the
+// parameters of the run. The extension is left for the caller to add.
+func ComposeImageName(args GenerateArgs) (name string) {
+	name = fmt.Sprintf("%s.%s", args.Tag, ToString(args.ColourBasis))
 
-	if args.seed_image == nil {
-		name += fmt.Sprintf(".r%dg%db%d", args.start_red, args.start_green, args.start_blue)
+	if args.SeedImage == nil {
+		name += fmt.Sprintf(".r%dg%db%d", args.StartRed, args.StartGreen, args.StartBlue)
 	} else {
-		name += fmt.Sprintf(".rr%1.3f", args.seed_rejection_rate)
+		name += fmt.Sprintf(".rr%1.3f", args.SeedRejectionRate)
 	}
 
-	name += fmt.Sprintf(".x%dy%d.blur%d.ch%d.cpu%d", args.start_x, args.start_y, args.blur, args.chan_size, runtime.GOMAXPROCS(0))
+	name += fmt.Sprintf(".x%dy%d.blur%d.ch%d.cpu%d", args.StartX, args.StartY, args.Blur, args.ChanSize, runtime.GOMAXPROCS(0))
 
-	if args.flip_draw {
+	if args.FlipDraw {
 		name += ".flip"
 	}
 
-	if args.echospace > 0 {
-		name += fmt.Sprintf(".es%1.5f", args.echospace)
+	if args.Echospace > 0 {
+		name += fmt.Sprintf(".es%1.5f", args.Echospace)
 	}
 
-	name += ".png"
 	return
 }
 
+// GenerateArgs describes a run. Start from NewGenerateArgs to get the
+// defaults the command line uses.
 type GenerateArgs struct {
-	cpus int
-	chan_size int32
-	blur int32
-	colour_basis ColourBasis
-	echospace float64
-	flip_draw bool
-	draw_ir bool
-
-	name string
-	tag string
-
-	seed_image image.Image
-	seed_rejection_rate float64
-	reseed_dupes bool
-	chroma_colour int
-
-	start_red int
-	start_green int
-	start_blue int
-	start_x int
-	start_y int
-
-	height int
-	width int
-
-	update func(pic image.Image)
-	update_freq int32
+	// CPUs is the GOMAXPROCS setting the run was made with. Generate leaves
+	// GOMAXPROCS alone; it's up to the caller to apply this.
+	CPUs        int
+	ChanSize    int32
+	Blur        int32
+	ColourBasis ColourBasis
+	Echospace   float64
+	FlipDraw    bool
+
+	// DrawIR, Name and Tag are for front ends that save the images; Generate
+	// doesn't write any files besides checkpoints.
+	DrawIR bool
+	Name   string
+	Tag    string
+
+	SeedImage         image.Image
+	SeedRejectionRate float64
+	ReseedDupes       bool
+	ChromaColour      int
+
+	// Palette limits the run to the colours of the palette image, which
+	// should have at least as many as the canvas has pixels.
+	Palette image.Image
+
+	// SeedImagePath and PalettePath name the files SeedImage and Palette
+	// came from, for the image metadata. Generate doesn't open them.
+	SeedImagePath string
+	PalettePath   string
+
+	StartRed   int
+	StartGreen int
+	StartBlue  int
+	StartX     int
+	StartY     int
+	// Seeds are more seed points, put in after the start point or the
+	// seed image's.
+	Seeds []SeedPixel
+
+	Height int
+	Width  int
+
+	// Update receives a copy of the canvas every UpdateFreq'th of the
+	// image and once it's done, on a goroutine of its own.
+	Update     func(pic image.Image, p Progress)
+	UpdateFreq int32
+	// Preview, if set, is brought up to date at each update instead, and
+	// Update is passed a nil picture until the finished one, to read what
+	// it wants from Preview without a copy of the whole canvas each time.
+	Preview *PreviewBuffer
+	// Progress is called from the filling goroutine, so it shouldn't dawdle.
+	Progress func(p Progress)
+	Frames   []FrameHook
+	// FillOrder, if set, is filled in with the order the pixels went in.
+	FillOrder *FillOrder
+	// FillStats, if set, is filled in with measures of how the fill went.
+	// A resumed run only measures the pixels filled since the checkpoint.
+	FillStats *FillStats
+	// Pause, if set, can hold the run still part way through.
+	Pause *Pauser
+	// Logf receives the run's informational messages. nil discards them.
+	Logf func(format string, v ...interface{})
+
+	// A non-zero RNGSeed makes the run deterministic.
+	RNGSeed int64
+
+	CheckpointPath     string
+	CheckpointInterval time.Duration
+	ResumePath         string
+}
+
+func NewGenerateArgs() GenerateArgs {
+	return GenerateArgs{
+		CPUs:               -1,
+		ChanSize:           8,
+		Blur:               1,
+		ColourBasis:        RGB,
+		Tag:                "art",
+		ChromaColour:       0xFF00FF,
+		Width:              MaxWidth,
+		Height:             MaxHeight,
+		UpdateFreq:         10,
+		CheckpointInterval: 10 * time.Minute,
+	}
+}
+
+func (args *GenerateArgs) logf(format string, v ...interface{}) {
+	if args.Logf != nil {
+		args.Logf(format, v...)
+	}
 }
 
-func Generate(args GenerateArgs) {
-	// Also affects CPU scheduling I suppose :)
-	if args.cpus != 0 {
-		if (args.cpus < 0) || (args.cpus > runtime.NumCPU()) {
-			args.cpus = runtime.NumCPU()
+// FrameHook asks for a copy of the canvas every Every pixels, and once more
+// when the fill is done if that didn't land on a multiple of Every. Frame is
+// called from the filling goroutine, which waits for it; the picture is
+// shared between hooks due at the same time and mustn't be modified, but
+// nothing else writes to it either, so it can be kept.
+//
+// Due, if set, is asked after each pixel whether a frame is wanted then,
+// besides the ones every Every pixels. It's called often, so should be
+// quick. Hooks with a Due always get a frame when the fill is done, and
+// may get the same count twice.
+type FrameHook struct {
+	Every int
+	Due   func(filled int) bool
+	Frame func(pic *image.RGBA, filled int)
+}
+
+func callFrameHooks(args GenerateArgs, pArray *PixelArray, filled int, done bool) {
+	var pic *image.RGBA
+	for _, hook := range args.Frames {
+		if hook.Every < 1 && hook.Due == nil {
+			continue
+		}
+		onBeat := hook.Every > 0 && filled%hook.Every == 0
+		if done {
+			// at the end only if the last pixel wasn't on the beat
+			if onBeat && hook.Due == nil {
+				continue
+			}
+		} else if !onBeat && (hook.Due == nil || !hook.Due(filled)) {
+			continue
+		}
+		if pic == nil {
+			pic = pArray.ImageNRGBA(args.Width, args.Height, args.FlipDraw)
 		}
-		runtime.GOMAXPROCS(args.cpus)
-		args.cpus = runtime.GOMAXPROCS(0)
+		hook.Frame(pic, filled)
+	}
+}
+
+// ResumeArgs returns args with the image settings of the checkpoint at
+// args.ResumePath, which is what Generate will run with. Front ends use it
+// to name the output before the run starts.
+func ResumeArgs(args GenerateArgs) (GenerateArgs, error) {
+	cp, err := readCheckpoint(args.ResumePath)
+	if err != nil {
+		return args, err
+	}
+	return cp.apply(args), nil
+}
+
+// Generate fills an image according to args and returns it. It stops early
+// with ctx's error if ctx is cancelled.
+func Generate(ctx context.Context, args GenerateArgs) (image.Image, error) {
+	var resume *checkpoint
+	if args.ResumePath != "" {
+		var err error
+		resume, err = readCheckpoint(args.ResumePath)
+		if err != nil {
+			return nil, err
+		}
+		args = resume.apply(args)
+	}
+
+	if args.Width < 1 || args.Width > MaxWidth || args.Height < 1 || args.Height > MaxHeight {
+		return nil, fmt.Errorf("image size %dx%d is outside 1x1 to %dx%d", args.Width, args.Height, MaxWidth, MaxHeight)
+	}
+	canvas := image.Rect(0, 0, args.Width, args.Height)
+	for _, sp := range args.Seeds {
+		if !sp.Pt.In(canvas) {
+			return nil, fmt.Errorf("seed at %d,%d is outside the image", sp.Pt.X, sp.Pt.Y)
+		}
+	}
+	if resume == nil {
+		start := canvas
+		if args.SeedImage != nil {
+			if start = args.SeedImage.Bounds(); !start.In(canvas) {
+				return nil, fmt.Errorf("seed image at %v doesn't fit in the %dx%d image", start, args.Width, args.Height)
+			}
+		}
+		if !image.Pt(args.StartX, args.StartY).In(start) {
+			return nil, fmt.Errorf("seed at %d,%d is outside %v", args.StartX, args.StartY, start)
+		}
+	}
+	if args.UpdateFreq < 1 {
+		args.UpdateFreq = 1
+	}
+
+	if args.CheckpointPath != "" && args.RNGSeed == 0 {
+		// checkpoints can only capture a run that doesn't depend on scheduling
+		args.RNGSeed = time.Now().UnixNano()
+		args.logf("Checkpointing with RNG seed %d", args.RNGSeed)
+	}
+
+	src := new(splitmix)
+	if args.RNGSeed != 0 {
+		src.Seed(args.RNGSeed)
+	} else {
+		src.Seed(time.Now().UnixNano())
 	}
 
 	var seedCh chan SeedPixel
 
-	var colours Colourspace = GetColourspace(args.colour_basis)
-	colours.SetEchospace(args.echospace)
+	var colours Colourspace = GetColourspace(args.ColourBasis)
+	colours.SetEchospace(args.Echospace)
+	if args.Palette != nil && resume == nil {
+		colours.Restrict(args.Palette)
+	}
 
-	picture := new(PixelArray)
+	picture := NewPixelArray(args.Width, args.Height)
 
-	if args.seed_image != nil {
-		bounds := args.seed_image.Bounds()
-		chanSize := (bounds.Max.X * bounds.Max.Y)
-		if args.seed_rejection_rate > 0 {
-			chanSize = int(float64(chanSize) * (1 - (args.seed_rejection_rate * args.seed_rejection_rate)))
+	if args.FillStats != nil {
+		args.FillStats.reset()
+	}
+	if args.FillOrder != nil {
+		args.FillOrder.reset(args.Width, args.Height)
+		if resume != nil {
+			if resume.FillOrder == nil {
+				args.logf("The checkpoint has no fill order, only pixels from here on are recorded")
+			} else {
+				copy(args.FillOrder.Index, resume.FillOrder)
+				args.FillOrder.Seeds = resume.FillSeeds
+			}
 		}
-		seedCh = make(chan SeedPixel, chanSize)
-		go processSeedImage(seedCh, args)
+	}
+
+	if resume != nil {
+		// no seeding, the checkpoint has the seeds in it already
+	} else if args.SeedImage != nil {
+		bounds := args.SeedImage.Bounds()
+		chanSize := (bounds.Max.X * bounds.Max.Y)
+		chanSize = int(float64(chanSize) * (1 - (args.SeedRejectionRate * args.SeedRejectionRate)))
+		seedCh = make(chan SeedPixel, chanSize+len(args.Seeds))
+		go processSeedImage(seedCh, rand.New(src), args)
 
 	} else {
 		// seeding based on params rather than seed image
-		seedCh = make(chan SeedPixel, 1)
+		seedCh = make(chan SeedPixel, 1+len(args.Seeds))
 		seedCh <- NewSeedPixel(
-			uint8(args.start_red),
-			uint8(args.start_green),
-			uint8(args.start_blue),
-			args.start_x,
-			args.start_y)
+			uint8(args.StartRed),
+			uint8(args.StartGreen),
+			uint8(args.StartBlue),
+			args.StartX,
+			args.StartY)
+		for _, sp := range args.Seeds {
+			seedCh <- sp
+		}
 
 		close(seedCh)
 	}
 
-	if args.name == "" {
-		args.name = composeImageName(args)
-	}
-
 	// changing channel size affects behaviour of colour filling;
 	// or rather, it makes CPU scheduling choices have a greater impact
-	ch := make(chan image.Point, args.chan_size)
-	_ = fillPixelArray(picture, colours, seedCh, ch, args)
+	var ch chan image.Point
+	var front *frontier
+	if args.RNGSeed != 0 {
+		front = newFrontier(int(args.ChanSize), src)
+	} else {
+		ch = make(chan image.Point, args.ChanSize)
+	}
+
+	if resume != nil {
+		resume.restore(picture, colours, front)
+	}
+	if args.Preview != nil {
+		args.Preview.reset(picture, args.Width, args.Height, args.FlipDraw)
+	}
+
+	_, err := fillPixelArray(ctx, picture, colours, seedCh, ch, front, resume, args)
+	if err != nil {
+		return nil, err
+	}
 
-	draw(picture.ImageNRGBA(args.width, args.height, args.flip_draw), args.name)
+	return picture.ImageNRGBA(args.Width, args.Height, args.FlipDraw), nil
 }
@@ -0,0 +1,75 @@
+package pixelart
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// seedTestImage is a 16x16 image whose left half has a colour of its own
+// for each pixel and whose right half is chroma keyed out.
+func seedTestImage() *image.RGBA {
+	pic := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			c := color.RGBA{uint8(x * 16), uint8(y * 16), 0x40, 0xFF}
+			if x >= 8 {
+				c = color.RGBA{0xFF, 0x00, 0xFF, 0xFF}
+			}
+			pic.SetRGBA(x, y, c)
+		}
+	}
+	return pic
+}
+
+func TestSeedImageRateZeroKeepsEverySeed(t *testing.T) {
+	seeds := seedTestImage()
+	args := NewGenerateArgs()
+	args.SeedImage = seeds
+	args.Width, args.Height = 16, 16
+	args.RNGSeed = 1
+	args.SeedRejectionRate = 0
+	args.FillOrder = new(FillOrder)
+
+	pic, err := Generate(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := args.FillOrder
+	if order.Seeds == 0 || order.Seeds > 8*16 {
+		t.Errorf("got %d seeds, want up to %d", order.Seeds, 8*16)
+	}
+	if n := order.Filled(); n != 16*16 {
+		t.Errorf("filled %d pixels, want %d", n, 16*16)
+	}
+	// the seeds go in as the colours they are in the seed image
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if int(order.At(x, y)) >= order.Seeds {
+				continue
+			}
+			got := color.RGBAModel.Convert(pic.At(x, y))
+			if want := seeds.RGBAAt(x, y); got != want {
+				t.Fatalf("seed at %d,%d is %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestSeedImageWithoutSeedsFails(t *testing.T) {
+	chroma := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := 0; i < len(chroma.Pix); i += 4 {
+		chroma.Pix[i], chroma.Pix[i+2], chroma.Pix[i+3] = 0xFF, 0xFF, 0xFF
+	}
+	// with and without an RNG seed, as they fill from different queues
+	for _, rngSeed := range []int64{0, 1} {
+		args := NewGenerateArgs()
+		args.SeedImage = chroma
+		args.Width, args.Height = 8, 8
+		args.RNGSeed = rngSeed
+		if _, err := Generate(context.Background(), args); err == nil {
+			t.Errorf("RNG seed %d: an all chroma seed image made an image", rngSeed)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package pixelart
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+)
+
+// encodePPM writes pic as a binary (P6) portable pixmap, dropping alpha.
+func encodePPM(w io.Writer, pic image.Image) error {
+	b := pic.Bounds()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "P6\n%d %d\n255\n", b.Dx(), b.Dy())
+
+	row := make([]byte, 0, 4*b.Dx())
+	rgb := make([]byte, 3*b.Dx())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row = nrgbaRow(row[:0], pic, y)
+		for x := 0; x < b.Dx(); x++ {
+			copy(rgb[3*x:3*x+3], row[4*x:4*x+3])
+		}
+		if _, err := bw.Write(rgb); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// encodePAM writes pic as a portable arbitrary map, with an alpha channel
+// only if pic might need one.
+func encodePAM(w io.Writer, pic image.Image) error {
+	b := pic.Bounds()
+	depth, tupltype := 4, "RGB_ALPHA"
+	if isOpaque(pic) {
+		depth, tupltype = 3, "RGB"
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "P7\nWIDTH %d\nHEIGHT %d\nDEPTH %d\nMAXVAL 255\nTUPLTYPE %s\nENDHDR\n", b.Dx(), b.Dy(), depth, tupltype)
+
+	row := make([]byte, 0, 4*b.Dx())
+	out := make([]byte, depth*b.Dx())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row = nrgbaRow(row[:0], pic, y)
+		for x := 0; x < b.Dx(); x++ {
+			copy(out[depth*x:depth*x+depth], row[4*x:4*x+depth])
+		}
+		if _, err := bw.Write(out); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,58 @@
+package pixelart
+
+import (
+	"context"
+	"sync"
+)
+
+// Pauser holds a run still between calls to Pause and Resume. The zero
+// value isn't paused. Generate only looks at it every few thousand pixels,
+// so a run can go on a little after Pause returns.
+type Pauser struct {
+	mu sync.Mutex
+	// resume is closed to let the run go again; it's nil when not paused
+	resume chan struct{}
+}
+
+func (p *Pauser) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resume == nil {
+		p.resume = make(chan struct{})
+	}
+}
+
+func (p *Pauser) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resume != nil {
+		close(p.resume)
+		p.resume = nil
+	}
+}
+
+func (p *Pauser) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.resume != nil
+}
+
+// wait blocks while p is paused, giving up with ctx's error if ctx is
+// cancelled. A nil Pauser never waits.
+func (p *Pauser) wait(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	resume := p.resume
+	p.mu.Unlock()
+	if resume == nil {
+		return nil
+	}
+	select {
+	case <-resume:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,212 @@
+package pixelart
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Metadata is text saved alongside an image, keyword to value. The PNG
+// encoder writes it as tEXt chunks, or iTXt for anything that isn't ASCII;
+// the other formats leave it out.
+type Metadata map[string]string
+
+// metadataPrefix marks the keywords that hold generation settings.
+const metadataPrefix = "pixelart:"
+
+// ArgsMetadata records the settings of args, so the image can be made again
+// with Metadata.Args. Only a run with an RNG seed comes out the same twice.
+func ArgsMetadata(args GenerateArgs) Metadata {
+	meta := Metadata{"Software": "pixelart"}
+	for flag, value := range ArgsSettings(args) {
+		meta[metadataPrefix+flag] = value
+	}
+	return meta
+}
+
+// Settings picks out the generation settings in meta.
+func (meta Metadata) Settings() Settings {
+	s := make(Settings)
+	for key, value := range meta {
+		if flag := strings.TrimPrefix(key, metadataPrefix); flag != key {
+			s[flag] = value
+		}
+	}
+	return s
+}
+
+// Args sets the fields of args that meta records, as Settings.Args does.
+func (meta Metadata) Args(args GenerateArgs) (GenerateArgs, error) {
+	s := meta.Settings()
+	if len(s) == 0 {
+		return args, errors.New("no pixelart settings in metadata")
+	}
+	return s.Args(args)
+}
+
+// encodePNGText copies the PNG in b to w with meta added after the header,
+// in keyword order.
+func encodePNGText(w io.Writer, b []byte, meta Metadata) error {
+	if len(b) < 8+12 {
+		return errors.New("short PNG")
+	}
+	// the header is always the first chunk
+	ihdrEnd := 8 + 12 + int(binary.BigEndian.Uint32(b[8:]))
+	if _, err := w.Write(b[:ihdrEnd]); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(meta))
+	for key := range meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if len(key) < 1 || len(key) > 79 {
+			return fmt.Errorf("PNG text keyword %q must be 1 to 79 bytes", key)
+		}
+		var typ string
+		var data []byte
+		if value := meta[key]; isASCII(value) {
+			typ = "tEXt"
+			data = append(append([]byte(key), 0), value...)
+		} else {
+			// uncompressed, no language tag, no translated keyword
+			typ = "iTXt"
+			data = append(append([]byte(key), 0, 0, 0, 0, 0), value...)
+		}
+		if err := writePNGChunk(w, typ, data); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(b[ihdrEnd:])
+	return err
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// maxTextChunk is the largest text chunk ReadPNGMetadata reads; larger ones
+// are skipped rather than read into memory.
+const maxTextChunk = 1 << 20
+
+// errTextTooLong is a compressed text chunk that inflates past maxTextChunk.
+var errTextTooLong = errors.New("PNG text chunk is too long")
+
+// ReadPNGMetadata reads the tEXt, zTXt and iTXt chunks of the PNG in r.
+// Chunks with over 1 MiB of text are skipped.
+func ReadPNGMetadata(r io.Reader) (Metadata, error) {
+	var sig [8]byte
+	if _, err := io.ReadFull(r, sig[:]); err != nil {
+		return nil, err
+	}
+	if string(sig[:]) != "\x89PNG\r\n\x1a\n" {
+		return nil, errors.New("not a PNG file")
+	}
+
+	meta := make(Metadata)
+	for {
+		var head [8]byte
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return nil, fmt.Errorf("truncated PNG: %v", err)
+		}
+		n := binary.BigEndian.Uint32(head[:4])
+		typ := string(head[4:])
+		switch {
+		case (typ == "tEXt" || typ == "zTXt" || typ == "iTXt") && n <= maxTextChunk:
+			data := make([]byte, n)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, fmt.Errorf("truncated PNG: %v", err)
+			}
+			key, value, err := pngText(typ, data)
+			if err == errTextTooLong {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			meta[key] = value
+		case typ == "IEND":
+			return meta, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(n)); err != nil {
+				return nil, fmt.Errorf("truncated PNG: %v", err)
+			}
+		}
+		// skip the CRC
+		if _, err := io.CopyN(io.Discard, r, 4); err != nil {
+			return nil, fmt.Errorf("truncated PNG: %v", err)
+		}
+	}
+}
+
+// pngText decodes the keyword and text of a text chunk.
+func pngText(typ string, data []byte) (key, value string, err error) {
+	bad := fmt.Errorf("malformed PNG %s chunk", typ)
+	i := bytes.IndexByte(data, 0)
+	if i < 1 {
+		return "", "", bad
+	}
+	key, data = latin1(data[:i]), data[i+1:]
+
+	switch typ {
+	case "tEXt":
+		return key, latin1(data), nil
+	case "zTXt":
+		if len(data) < 1 {
+			return "", "", bad
+		}
+		text, err := inflate(data[1:])
+		return key, latin1(text), err
+	}
+
+	// iTXt: compression flag and method, language tag, translated keyword
+	if len(data) < 2 {
+		return "", "", bad
+	}
+	compressed := data[0] == 1
+	data = data[2:]
+	for skip := 0; skip < 2; skip++ {
+		if i = bytes.IndexByte(data, 0); i < 0 {
+			return "", "", bad
+		}
+		data = data[i+1:]
+	}
+	if compressed {
+		data, err = inflate(data)
+	}
+	return key, string(data), err
+}
+
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+func inflate(b []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	b, err = io.ReadAll(io.LimitReader(zr, maxTextChunk+1))
+	if err == nil && len(b) > maxTextChunk {
+		return nil, errTextTooLong
+	}
+	return b, err
+}
@@ -0,0 +1,117 @@
+package pixelart
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestPNGMetadataRoundTrip(t *testing.T) {
+	want := Metadata{
+		"Software":       "pixelart",
+		"pixelart:width": "4",
+		"pixelart:name":  "out.png",
+		"Comment":        "colours: rouge, vert, bleu – and ünïcödé",
+		"pixelart:tag":   "tab\tand ~",
+	}
+	f, err := LookupFormat("png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = f.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 3)), EncodeOptions{Metadata: want}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = png.Decode(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("the PNG with metadata doesn't decode: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("iTXtComment")) {
+		t.Error("the text that isn't ASCII wasn't saved as iTXt")
+	}
+
+	got, err := ReadPNGMetadata(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Errorf("read %d keywords, want %d", len(got), len(want))
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%q is %q, want %q", key, got[key], value)
+		}
+	}
+}
+
+// withChunks splices chunks in after the header of the PNG in b.
+func withChunks(t *testing.T, b []byte, chunks ...func(*bytes.Buffer) error) []byte {
+	t.Helper()
+	ihdrEnd := 8 + 12 + int(binary.BigEndian.Uint32(b[8:]))
+	var buf bytes.Buffer
+	buf.Write(b[:ihdrEnd])
+	for _, chunk := range chunks {
+		if err := chunk(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf.Write(b[ihdrEnd:])
+	return buf.Bytes()
+}
+
+func chunk(typ string, data []byte) func(*bytes.Buffer) error {
+	return func(buf *bytes.Buffer) error {
+		return writePNGChunk(buf, typ, data)
+	}
+}
+
+func compress(t *testing.T, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(b)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestPNGMetadataSkipsOversizedText(t *testing.T) {
+	long := []byte(strings.Repeat("x", maxTextChunk+1))
+	b := withChunks(t, testPNG(t),
+		chunk("tEXt", append([]byte("long\x00"), long...)),
+		// small in the file, but over the limit once inflated
+		chunk("zTXt", append([]byte("zlong\x00\x00"), compress(t, long)...)),
+		chunk("iTXt", append([]byte("ilong\x00\x01\x00\x00\x00"), compress(t, long)...)),
+		chunk("zTXt", append([]byte("short\x00\x00"), compress(t, []byte("kept"))...)),
+		chunk("tEXt", []byte("Software\x00pixelart")),
+	)
+	if _, err := png.Decode(bytes.NewReader(b)); err != nil {
+		t.Fatalf("the test PNG doesn't decode: %v", err)
+	}
+
+	got, err := ReadPNGMetadata(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Metadata{"short": "kept", "Software": "pixelart"}
+	if len(got) != len(want) || got["short"] != want["short"] || got["Software"] != want["Software"] {
+		keys := make([]string, 0, len(got))
+		for key := range got {
+			keys = append(keys, key)
+		}
+		t.Errorf("read keywords %q, want only %q and %q", keys, "short", "Software")
+	}
+}
@@ -0,0 +1,201 @@
+package pixelart
+
+import (
+	"image"
+	"sync"
+)
+
+// PreviewBuffer is a copy of the canvas that a run keeps up to date at each
+// update, from just the pixels filled since the last, for front ends that
+// want to look often without copying the whole canvas each time. It can
+// also keep a mipmap: the canvas shrunk by a whole number, averaging each
+// square of pixels, small enough to draw in full every update.
+//
+// The run writes to it between pixels under a lock, so what's read through
+// it is always the canvas as it was at an update.
+type PreviewBuffer struct {
+	mipSize int
+
+	mu     sync.RWMutex
+	pic    *image.RGBA
+	mip    *image.RGBA
+	step   int
+	filled int
+
+	// the run's, between flushes: the pixels set since the last, up to
+	// maxPending of them, then just the columns they're in, which are
+	// copied from the canvas whole
+	flip     bool
+	pending  []pendingPixel
+	columns  []bool
+	colIdx   []int
+	dirty    []bool
+	dirtyIdx []int
+}
+
+// maxPending bounds the pixels kept between flushes, which with few updates
+// could be most of the canvas.
+const maxPending = 1 << 16
+
+type pendingPixel struct {
+	x, y    int
+	r, g, b uint8
+}
+
+// NewPreviewBuffer makes a buffer for GenerateArgs.Preview. mipSize is the
+// longest side the mipmap can have; 0 keeps none.
+func NewPreviewBuffer(mipSize int) *PreviewBuffer {
+	return &PreviewBuffer{mipSize: mipSize}
+}
+
+// Read calls f with the canvas, the mipmap if there is one, and how many
+// pixels were filled when they were last brought up to date. f mustn't
+// modify or keep them: the run waits for f to bring them up to date again.
+func (b *PreviewBuffer) Read(f func(pic, mip *image.RGBA, filled int)) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	f(b.pic, b.mip, b.filled)
+}
+
+// Snapshot copies the canvas. It's nil before the run starts.
+func (b *PreviewBuffer) Snapshot() (pic *image.RGBA, filled int) {
+	b.Read(func(p, _ *image.RGBA, n int) {
+		pic, filled = cloneRGBA(p), n
+	})
+	return
+}
+
+// Mipmap copies the mipmap. It's nil before the run starts, or if the
+// buffer doesn't keep one.
+func (b *PreviewBuffer) Mipmap() (mip *image.RGBA, filled int) {
+	b.Read(func(_, m *image.RGBA, n int) {
+		mip, filled = cloneRGBA(m), n
+	})
+	return
+}
+
+// Step is how many canvas pixels each way a mipmap pixel covers.
+func (b *PreviewBuffer) Step() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.step
+}
+
+func cloneRGBA(pic *image.RGBA) *image.RGBA {
+	if pic == nil {
+		return nil
+	}
+	c := *pic
+	c.Pix = append([]uint8(nil), pic.Pix...)
+	return &c
+}
+
+// reset loads the whole canvas, as the run starts or resumes.
+func (b *PreviewBuffer) reset(pArray *PixelArray, width, height int, flip bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pic = pArray.ImageNRGBA(width, height, flip)
+	b.flip, b.filled = flip, 0
+	b.pending = make([]pendingPixel, 0, minint(maxPending, width*height))
+	b.columns, b.colIdx = make([]bool, width), b.colIdx[:0]
+	b.mip, b.step, b.dirty, b.dirtyIdx = nil, 0, nil, nil
+	if b.mipSize < 1 {
+		return
+	}
+	b.step = (maxint(width, height) + b.mipSize - 1) / b.mipSize
+	b.mip = image.NewRGBA(image.Rect(0, 0, (width+b.step-1)/b.step, (height+b.step-1)/b.step))
+	b.dirty = make([]bool, len(b.mip.Pix)/4)
+	for i := range b.dirty {
+		b.dirtyIdx = append(b.dirtyIdx, i)
+	}
+	b.redoMip()
+}
+
+// set notes a pixel for the next flush. It's only called by the run, and
+// doesn't take the lock.
+func (b *PreviewBuffer) set(x, y int, c Colour24) {
+	if len(b.pending) >= maxPending {
+		if !b.columns[x] {
+			b.columns[x] = true
+			b.colIdx = append(b.colIdx, x)
+		}
+		return
+	}
+	p := pendingPixel{x, y, c.red, c.green, c.blue}
+	if b.flip {
+		p.r, p.g, p.b = 255-p.r, 255-p.g, 255-p.b
+	}
+	b.pending = append(b.pending, p)
+}
+
+// flush brings the buffer up to date with the pixels set since the last,
+// from pArray for those set past maxPending.
+func (b *PreviewBuffer) flush(pArray *PixelArray, filled int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, p := range b.pending {
+		i := b.pic.PixOffset(p.x, p.y)
+		b.pic.Pix[i], b.pic.Pix[i+1], b.pic.Pix[i+2], b.pic.Pix[i+3] = p.r, p.g, p.b, FullAlpha
+		b.markDirty(p.x, p.y)
+	}
+	b.pending = b.pending[:0]
+
+	var flip uint8
+	if b.flip {
+		flip = 0xFF
+	}
+	height := b.pic.Bounds().Dy()
+	for _, x := range b.colIdx {
+		b.columns[x] = false
+		column := (*pArray)[x]
+		for y := 0; y < height; y++ {
+			c := column[y].Colour
+			i := b.pic.PixOffset(x, y)
+			b.pic.Pix[i], b.pic.Pix[i+1], b.pic.Pix[i+2], b.pic.Pix[i+3] = c.red^flip, c.green^flip, c.blue^flip, FullAlpha
+		}
+		for y := 0; y < height; y += maxint(1, b.step) {
+			b.markDirty(x, y)
+		}
+	}
+	b.colIdx = b.colIdx[:0]
+
+	b.filled = filled
+	if b.mip != nil {
+		b.redoMip()
+	}
+}
+
+// markDirty notes the mipmap square x, y is in needs averaging again. b.mu
+// is held.
+func (b *PreviewBuffer) markDirty(x, y int) {
+	if b.mip == nil {
+		return
+	}
+	cell := (y/b.step)*b.mip.Bounds().Dx() + x/b.step
+	if !b.dirty[cell] {
+		b.dirty[cell] = true
+		b.dirtyIdx = append(b.dirtyIdx, cell)
+	}
+}
+
+// redoMip averages the dirty squares of the canvas into the mipmap. b.mu
+// is held.
+func (b *PreviewBuffer) redoMip() {
+	w := b.mip.Bounds().Dx()
+	bounds := b.pic.Bounds()
+	for _, cell := range b.dirtyIdx {
+		b.dirty[cell] = false
+		square := image.Rect(cell%w*b.step, cell/w*b.step, (cell%w+1)*b.step, (cell/w+1)*b.step).Intersect(bounds)
+		var r, g, bl, n int
+		for y := square.Min.Y; y < square.Max.Y; y++ {
+			row := b.pic.Pix[b.pic.PixOffset(square.Min.X, y):b.pic.PixOffset(square.Max.X, y)]
+			for i := 0; i < len(row); i += 4 {
+				r, g, bl = r+int(row[i]), g+int(row[i+1]), bl+int(row[i+2])
+				n++
+			}
+		}
+		o := 4 * cell
+		b.mip.Pix[o], b.mip.Pix[o+1], b.mip.Pix[o+2], b.mip.Pix[o+3] = uint8(r/n), uint8(g/n), uint8(bl/n), FullAlpha
+	}
+	b.dirtyIdx = b.dirtyIdx[:0]
+}
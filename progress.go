@@ -0,0 +1,159 @@
+package pixelart
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"time"
+)
+
+// Progress describes how far a run has got. Generate sends one to
+// GenerateArgs.Progress every UpdateFreq'th of the image and once more
+// when the fill is done.
+type Progress struct {
+	Filled int
+	Total  int
+
+	// PixelsPerSecond is measured since the previous event.
+	PixelsPerSecond float64
+	// SearchRadius is the mean distance searched through the colour cube
+	// for each pixel since the previous event.
+	SearchRadius float64
+
+	Elapsed time.Duration
+	ETA     time.Duration
+
+	ColoursRemaining int
+	Done             bool
+}
+
+func (p Progress) Fraction() float64 {
+	if p.Total == 0 {
+		return 0
+	}
+	return float64(p.Filled) / float64(p.Total)
+}
+
+func (p Progress) String() string {
+	if p.Done {
+		return fmt.Sprintf("%d of %d pixels filled in %s", p.Filled, p.Total, p.Elapsed.Round(time.Second))
+	}
+	return fmt.Sprintf("%2.1f%% of pixels filled, %.0f px/s, search radius %.2f, %s remaining",
+		p.Fraction()*100, p.PixelsPerSecond, p.SearchRadius, p.ETA.Round(time.Second))
+}
+
+// MarshalJSON gives the durations in seconds and adds the fraction done,
+// for clients that aren't written in go.
+func (p Progress) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Filled           int     `json:"filled"`
+		Total            int     `json:"total"`
+		Fraction         float64 `json:"fraction"`
+		PixelsPerSecond  float64 `json:"pixels_per_second"`
+		SearchRadius     float64 `json:"search_radius"`
+		Elapsed          float64 `json:"elapsed_seconds"`
+		ETA              float64 `json:"eta_seconds"`
+		ColoursRemaining int     `json:"colours_remaining"`
+		Done             bool    `json:"done"`
+	}{p.Filled, p.Total, p.Fraction(), p.PixelsPerSecond, p.SearchRadius,
+		p.Elapsed.Seconds(), p.ETA.Seconds(), p.ColoursRemaining, p.Done})
+}
+
+// progressMeter turns the fill loop's counters into Progress events.
+type progressMeter struct {
+	start time.Time
+	total int
+
+	last         time.Time
+	lastFilled   int
+	lastSearches int64
+	lastRadii    int64
+}
+
+func newProgressMeter(total, filled int, cspace Colourspace) *progressMeter {
+	now := time.Now()
+	m := &progressMeter{start: now, total: total, last: now, lastFilled: filled}
+	m.lastSearches, m.lastRadii = cspace.SearchStats()
+	return m
+}
+
+func (m *progressMeter) Event(filled int, cspace Colourspace) (p Progress) {
+	now := time.Now()
+	searches, radii := cspace.SearchStats()
+
+	p.Filled = filled
+	p.Total = m.total
+	p.Elapsed = now.Sub(m.start)
+	p.ColoursRemaining = int(cspace.GetRemainingColourCount())
+
+	if dt := now.Sub(m.last).Seconds(); dt > 0 {
+		p.PixelsPerSecond = float64(filled-m.lastFilled) / dt
+	}
+	if searches > m.lastSearches {
+		p.SearchRadius = float64(radii-m.lastRadii) / float64(searches-m.lastSearches)
+	}
+	if p.PixelsPerSecond > 0 {
+		p.ETA = time.Duration(float64(m.total-filled) / p.PixelsPerSecond * float64(time.Second))
+	}
+
+	m.last, m.lastFilled = now, filled
+	m.lastSearches, m.lastRadii = searches, radii
+	return
+}
+
+// reporter makes the Progress and Update calls every UpdateFreq'th of a
+// run, and the frame hooks' last call when it's done.
+type reporter struct {
+	args     GenerateArgs
+	meter    *progressMeter
+	tag      int32
+	fraction int32
+}
+
+func newReporter(args GenerateArgs, filled, tag int32, cspace Colourspace) *reporter {
+	return &reporter{
+		args:     args,
+		meter:    newProgressMeter(args.Width*args.Height, int(filled), cspace),
+		tag:      tag,
+		fraction: int32(args.Width*args.Height) / args.UpdateFreq,
+	}
+}
+
+// Tick is called as the count'th pixel is filled.
+func (r *reporter) Tick(count int32, pArray *PixelArray, cspace Colourspace) {
+	if count > r.tag*r.fraction && r.tag < r.args.UpdateFreq {
+		p := r.meter.Event(int(count), cspace)
+		if r.args.Preview != nil {
+			r.args.Preview.flush(pArray, int(count))
+		}
+		if r.args.Progress != nil {
+			r.args.Progress(p)
+		}
+		r.tag++
+
+		if r.args.Update != nil {
+			var pic image.Image
+			if r.args.Preview == nil {
+				pic = pArray.ImageNRGBA(r.args.Width, r.args.Height, r.args.FlipDraw)
+			}
+			go r.args.Update(pic, p)
+		}
+	}
+}
+
+func (r *reporter) Done(count int32, pArray *PixelArray, cspace Colourspace) {
+	callFrameHooks(r.args, pArray, int(count), true)
+
+	if r.args.Preview != nil {
+		r.args.Preview.flush(pArray, int(count))
+	}
+	p := r.meter.Event(int(count), cspace)
+	p.Done = true
+	if r.args.Progress != nil {
+		r.args.Progress(p)
+	}
+
+	if r.args.Update != nil {
+		go r.args.Update(pArray.ImageNRGBA(r.args.Width, r.args.Height, r.args.FlipDraw), p)
+	}
+}
@@ -0,0 +1,98 @@
+package pixelart
+
+import (
+	"bufio"
+	"encoding/binary"
+	"image"
+	"io"
+)
+
+// QOI encoding, after the specification at https://qoiformat.org
+
+const (
+	qoiOpIndex = 0x00
+	qoiOpDiff  = 0x40
+	qoiOpLuma  = 0x80
+	qoiOpRun   = 0xc0
+	qoiOpRGB   = 0xfe
+	qoiOpRGBA  = 0xff
+)
+
+func encodeQOI(w io.Writer, pic image.Image) error {
+	b := pic.Bounds()
+	channels := byte(4)
+	if isOpaque(pic) {
+		channels = 3
+	}
+
+	bw := bufio.NewWriter(w)
+	header := make([]byte, 14)
+	copy(header, "qoif")
+	binary.BigEndian.PutUint32(header[4:], uint32(b.Dx()))
+	binary.BigEndian.PutUint32(header[8:], uint32(b.Dy()))
+	header[12] = channels
+	header[13] = 0 // sRGB with linear alpha
+	bw.Write(header)
+
+	var index [64][4]byte
+	prev := [4]byte{0, 0, 0, 255}
+	run := 0
+
+	row := make([]byte, 0, 4*b.Dx())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row = nrgbaRow(row[:0], pic, y)
+		for x := 0; x < b.Dx(); x++ {
+			var px [4]byte
+			copy(px[:], row[4*x:4*x+4])
+
+			if px == prev {
+				run++
+				if run == 62 {
+					bw.WriteByte(qoiOpRun | byte(run-1))
+					run = 0
+				}
+				continue
+			}
+			if run > 0 {
+				bw.WriteByte(qoiOpRun | byte(run-1))
+				run = 0
+			}
+
+			hash := (int(px[0])*3 + int(px[1])*5 + int(px[2])*7 + int(px[3])*11) % 64
+			if index[hash] == px {
+				bw.WriteByte(qoiOpIndex | byte(hash))
+				prev = px
+				continue
+			}
+			index[hash] = px
+
+			if px[3] != prev[3] {
+				bw.Write([]byte{qoiOpRGBA, px[0], px[1], px[2], px[3]})
+				prev = px
+				continue
+			}
+
+			dr := int8(px[0] - prev[0])
+			dg := int8(px[1] - prev[1])
+			db := int8(px[2] - prev[2])
+			drdg := dr - dg
+			dbdg := db - dg
+
+			switch {
+			case dr >= -2 && dr <= 1 && dg >= -2 && dg <= 1 && db >= -2 && db <= 1:
+				bw.WriteByte(qoiOpDiff | byte(dr+2)<<4 | byte(dg+2)<<2 | byte(db+2))
+			case dg >= -32 && dg <= 31 && drdg >= -8 && drdg <= 7 && dbdg >= -8 && dbdg <= 7:
+				bw.Write([]byte{qoiOpLuma | byte(dg+32), byte(drdg+8)<<4 | byte(dbdg+8)})
+			default:
+				bw.Write([]byte{qoiOpRGB, px[0], px[1], px[2]})
+			}
+			prev = px
+		}
+	}
+	if run > 0 {
+		bw.WriteByte(qoiOpRun | byte(run-1))
+	}
+
+	bw.Write([]byte{0, 0, 0, 0, 0, 0, 0, 1})
+	return bw.Flush()
+}
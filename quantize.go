@@ -0,0 +1,168 @@
+package pixelart
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// Colours are binned to 5 bits a channel while building palettes.
+const quantBits = 5
+
+func quantKey(r, g, b uint8) int {
+	return int(r>>(8-quantBits))<<(2*quantBits) | int(g>>(8-quantBits))<<quantBits | int(b>>(8-quantBits))
+}
+
+type quantBin struct {
+	count   int
+	r, g, b int // sums
+	key     int
+}
+
+// medianCut picks up to n colours to stand for pic by repeatedly splitting
+// the set of colours with the widest channel at its median.
+func medianCut(pic *image.RGBA, n int) color.Palette {
+	bins := make([]quantBin, 1<<(3*quantBits))
+	bounds := pic.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		i := pic.PixOffset(bounds.Min.X, y)
+		for x := bounds.Min.X; x < bounds.Max.X; x, i = x+1, i+4 {
+			r, g, b := pic.Pix[i], pic.Pix[i+1], pic.Pix[i+2]
+			bin := &bins[quantKey(r, g, b)]
+			bin.count++
+			bin.r += int(r)
+			bin.g += int(g)
+			bin.b += int(b)
+		}
+	}
+
+	var used []quantBin
+	for k, bin := range bins {
+		if bin.count > 0 {
+			bin.key = k
+			used = append(used, bin)
+		}
+	}
+
+	boxes := [][]quantBin{used}
+	for len(boxes) < n {
+		// split the box with the widest spread on any channel
+		widest, axis, spread := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			for c := 0; c < 3; c++ {
+				lo, hi := 1<<quantBits, -1
+				for _, bin := range box {
+					v := binChannel(bin, c)
+					lo, hi = minint(lo, v), maxint(hi, v)
+				}
+				if hi-lo > spread {
+					widest, axis, spread = i, c, hi-lo
+				}
+			}
+		}
+		if widest < 0 {
+			break
+		}
+
+		box := boxes[widest]
+		sort.Slice(box, func(i, j int) bool { return binChannel(box[i], axis) < binChannel(box[j], axis) })
+		total := 0
+		for _, bin := range box {
+			total += bin.count
+		}
+		cut, seen := 1, box[0].count
+		for cut < len(box)-1 && seen < total/2 {
+			seen += box[cut].count
+			cut++
+		}
+		boxes[widest] = box[:cut]
+		boxes = append(boxes, box[cut:])
+	}
+
+	pal := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		var count, r, g, b int
+		for _, bin := range box {
+			count += bin.count
+			r += bin.r
+			g += bin.g
+			b += bin.b
+		}
+		if count > 0 {
+			pal = append(pal, color.RGBA{uint8(r / count), uint8(g / count), uint8(b / count), FullAlpha})
+		}
+	}
+	if len(pal) == 0 {
+		pal = append(pal, color.RGBA{0, 0, 0, FullAlpha})
+	}
+	return pal
+}
+
+// binChannel gives the binned value of channel c (0 red, 1 green, 2 blue).
+func binChannel(bin quantBin, c int) int {
+	return bin.key >> (uint(2-c) * quantBits) & (1<<quantBits - 1)
+}
+
+// quantize maps pic onto pal, optionally with Floyd-Steinberg dithering.
+// Nearest colours are looked up once per bin rather than once per pixel.
+func quantize(pic *image.RGBA, pal color.Palette, dither bool) *image.Paletted {
+	bounds := pic.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	out := image.NewPaletted(image.Rect(0, 0, w, h), pal)
+
+	lookup := make([]int16, 1<<(3*quantBits))
+	for i := range lookup {
+		lookup[i] = -1
+	}
+	nearest := func(r, g, b uint8) uint8 {
+		k := quantKey(r, g, b)
+		if lookup[k] < 0 {
+			lookup[k] = int16(pal.Index(color.RGBA{r, g, b, FullAlpha}))
+		}
+		return uint8(lookup[k])
+	}
+
+	// error carried to this row and the next, with a pixel of slack each side
+	cur := make([]int32, 3*(w+2))
+	next := make([]int32, 3*(w+2))
+	clamp := func(v int32) uint8 {
+		if v < 0 {
+			return 0
+		}
+		if v > 255 {
+			return 255
+		}
+		return uint8(v)
+	}
+
+	for y := 0; y < h; y++ {
+		i := pic.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+		for x := 0; x < w; x, i = x+1, i+4 {
+			e := 3 * (x + 1)
+			r := clamp(int32(pic.Pix[i]) + cur[e]/16)
+			g := clamp(int32(pic.Pix[i+1]) + cur[e+1]/16)
+			b := clamp(int32(pic.Pix[i+2]) + cur[e+2]/16)
+
+			idx := nearest(r, g, b)
+			out.Pix[y*out.Stride+x] = idx
+
+			if dither {
+				p := pal[idx].(color.RGBA)
+				for c, diff := range [3]int32{int32(r) - int32(p.R), int32(g) - int32(p.G), int32(b) - int32(p.B)} {
+					cur[e+3+c] += diff * 7
+					next[e-3+c] += diff * 3
+					next[e+c] += diff * 5
+					next[e+3+c] += diff
+				}
+			}
+		}
+		cur, next = next, cur
+		for k := range next {
+			next[k] = 0
+		}
+	}
+	return out
+}
@@ -0,0 +1,91 @@
+package pixelart
+
+import (
+	"context"
+	"fmt"
+	"image"
+)
+
+// Replay fills a canvas again in the order recorded in order, choosing each
+// colour the way Generate does under the ColourBasis, Echospace, Blur,
+// Palette and FlipDraw of args. The seeds are given the colour of
+// args.SeedImage where they sit, or the start colour if there's no seed
+// image. Progress, Update, Preview and Frames are used as they would be by
+// Generate; the size and seeding fields of args are ignored.
+func Replay(ctx context.Context, order *FillOrder, args GenerateArgs) (image.Image, error) {
+	if order.Width < 1 || order.Width > MaxWidth || order.Height < 1 || order.Height > MaxHeight {
+		return nil, fmt.Errorf("fill order size %dx%d is outside 1x1 to %dx%d", order.Width, order.Height, MaxWidth, MaxHeight)
+	}
+	args.Width, args.Height = order.Width, order.Height
+	if args.UpdateFreq < 1 {
+		args.UpdateFreq = 1
+	}
+
+	var colours Colourspace = GetColourspace(args.ColourBasis)
+	colours.SetEchospace(args.Echospace)
+	if args.Palette != nil {
+		colours.Restrict(args.Palette)
+	}
+
+	points, err := order.Points()
+	if err != nil {
+		return nil, err
+	}
+
+	picture := NewPixelArray(args.Width, args.Height)
+	if args.FillStats != nil {
+		args.FillStats.reset()
+	}
+	if args.Preview != nil {
+		args.Preview.reset(picture, args.Width, args.Height, args.FlipDraw)
+	}
+	report := newReporter(args, 0, 1, colours)
+
+	var count int32
+	for _, pt := range points {
+		if count%4096 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		var target Colour24
+		if int(count) < order.Seeds {
+			target = replaySeedColour(pt, args)
+		} else {
+			target = picture.TargetColourAt(int32(pt.X), int32(pt.Y), args.Blur, args.Width, args.Height)
+			report.Tick(count, picture, colours)
+		}
+		chosen := colours.PopColour(target)
+		if args.FillStats != nil && int(count) >= order.Seeds {
+			args.FillStats.add(target, chosen)
+		}
+
+		if count == MaxWidth*MaxHeight*15/16 {
+			args.logf("Endgame optimisation... (this last one takes the longest :( )")
+			colours.PrepOpt()
+		}
+
+		picture.Set(int32(pt.X), int32(pt.Y), chosen)
+		if args.Preview != nil {
+			args.Preview.set(pt.X, pt.Y, chosen)
+		}
+		count++
+		callFrameHooks(args, picture, int(count), false)
+	}
+
+	report.Done(count, picture, colours)
+	return picture.ImageNRGBA(args.Width, args.Height, args.FlipDraw), nil
+}
+
+func replaySeedColour(pt image.Point, args GenerateArgs) Colour24 {
+	if args.SeedImage == nil {
+		return Colour24{uint8(args.StartRed), uint8(args.StartGreen), uint8(args.StartBlue)}
+	}
+	b := args.SeedImage.Bounds()
+	r, g, bl, _ := args.SeedImage.At(b.Min.X+pt.X, b.Min.Y+pt.Y).RGBA()
+	c := Colour24{uint8(r >> 8), uint8(g >> 8), uint8(bl >> 8)}
+	if args.FlipDraw {
+		// as processSeedImage does, so the seed comes out as it went in
+		c = Colour24{255 - c.red, 255 - c.green, 255 - c.blue}
+	}
+	return c
+}
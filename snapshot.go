@@ -0,0 +1,174 @@
+package pixelart
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SnapshotOptions describe when a Snapshotter saves the canvas and where.
+// A snapshot is taken whenever any of the triggers is due.
+type SnapshotOptions struct {
+	// Fractions takes one every 1/Fractions of the image, Pixels one every
+	// Pixels pixels and Interval one every Interval of wall-clock time.
+	// 0 turns each off.
+	Fractions int
+	Pixels    int
+	Interval  time.Duration
+
+	// Dir is made if it isn't there; "" is the working directory.
+	Dir string
+	// Name is the file name without its extension, in which {tag} is the
+	// run's tag, {n} the snapshot's number, {filled} the pixels filled and
+	// {percent} how far through the run it is. The numbers are zero padded
+	// so the files sort in order. It's "{tag}.{n}" if empty.
+	Name string
+	Tag  string
+
+	// Scale shrinks the snapshots; 0 leaves them at full size.
+	Scale  float64
+	Format Format
+	Encode EncodeOptions
+
+	// Keep is how many of the newest snapshots to keep, deleting older
+	// ones as new ones are written. 0 keeps them all.
+	Keep int
+}
+
+// Snapshots reports whether opts has any triggers.
+func (opts SnapshotOptions) Snapshots() bool {
+	return opts.Fractions > 0 || opts.Pixels > 0 || opts.Interval > 0
+}
+
+// Snapshotter saves copies of the canvas as a run goes. The copies are the
+// frame hooks', taken between pixels, and are written out on a goroutine of
+// its own so the fill only waits if it falls behind.
+type Snapshotter struct {
+	opts  SnapshotOptions
+	total int
+
+	// the fill goroutine's
+	n          int
+	lastFilled int
+	lastTime   time.Time
+
+	queue chan snapshot
+	done  chan struct{}
+	err   error
+	// written are the files kept, oldest first
+	written []string
+}
+
+type snapshot struct {
+	pic    *image.RGBA
+	n      int
+	filled int
+}
+
+// the pixels between looks at the clock for Interval
+const snapshotClockEvery = 1024
+
+// NewSnapshotter makes opts.Dir and starts the writer for a run of total
+// pixels. Close must be called once the run's over.
+func NewSnapshotter(opts SnapshotOptions, total int) (*Snapshotter, error) {
+	if opts.Name == "" {
+		opts.Name = "{tag}.{n}"
+	}
+	if opts.Dir != "" {
+		if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	s := &Snapshotter{
+		opts:       opts,
+		total:      total,
+		lastFilled: -1,
+		lastTime:   time.Now(),
+		queue:      make(chan snapshot, 2),
+		done:       make(chan struct{}),
+	}
+	go s.write()
+	return s, nil
+}
+
+// Hook is the FrameHook that gives the Snapshotter its pictures.
+func (s *Snapshotter) Hook() FrameHook {
+	return FrameHook{Due: s.due, Frame: s.frame}
+}
+
+func (s *Snapshotter) due(filled int) bool {
+	o := s.opts
+	// rounded up, so the last of the fractions is the finished image
+	if o.Fractions > 0 && filled%maxint(1, (s.total+o.Fractions-1)/o.Fractions) == 0 {
+		return true
+	}
+	if o.Pixels > 0 && filled%o.Pixels == 0 {
+		return true
+	}
+	return o.Interval > 0 && filled%snapshotClockEvery == 0 && time.Since(s.lastTime) >= o.Interval
+}
+
+func (s *Snapshotter) frame(pic *image.RGBA, filled int) {
+	if filled == s.lastFilled {
+		return
+	}
+	s.n++
+	s.lastFilled, s.lastTime = filled, time.Now()
+	// no one writes to pic after the hooks are done with it
+	s.queue <- snapshot{pic, s.n, filled}
+}
+
+func (s *Snapshotter) write() {
+	defer close(s.done)
+	for snap := range s.queue {
+		if s.err != nil {
+			continue
+		}
+		name := s.name(snap)
+		if s.err = s.save(name, snap.pic); s.err != nil {
+			continue
+		}
+		s.written = append(s.written, name)
+		for s.opts.Keep > 0 && len(s.written) > s.opts.Keep {
+			if err := os.Remove(s.written[0]); err != nil && !os.IsNotExist(err) {
+				s.err = err
+			}
+			s.written = s.written[1:]
+		}
+	}
+}
+
+func (s *Snapshotter) name(snap snapshot) string {
+	digits := len(strconv.Itoa(s.total))
+	r := strings.NewReplacer(
+		"{tag}", s.opts.Tag,
+		"{n}", fmt.Sprintf("%03d", snap.n),
+		"{filled}", fmt.Sprintf("%0*d", digits, snap.filled),
+		"{percent}", fmt.Sprintf("%05.1f", 100*float64(snap.filled)/float64(maxint(1, s.total))),
+	)
+	return filepath.Join(s.opts.Dir, r.Replace(s.opts.Name)+"."+s.opts.Format.Extensions[0])
+}
+
+func (s *Snapshotter) save(name string, pic *image.RGBA) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err = s.opts.Format.Encode(file, scaleImage(pic, s.opts.Scale), s.opts.Encode); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Close waits for the snapshots to be written and returns the first error
+// writing them, after which no more were written.
+func (s *Snapshotter) Close() error {
+	close(s.queue)
+	<-s.done
+	return s.err
+}
@@ -0,0 +1,169 @@
+package pixelart
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	hueBins       = 36
+	luminanceBins = 32
+	// greyChroma is the chroma below which a pixel has no hue to speak of
+	greyChroma = 8
+)
+
+// Stats are measures of how an image looks, for comparing the settings
+// that made it.
+type Stats struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// The difference between neighbours is the distance in RGB between each
+	// pixel and the ones to its right and below.
+	MeanNeighbourDifference float64 `json:"mean_neighbour_difference"`
+	MaxNeighbourDifference  float64 `json:"max_neighbour_difference"`
+	// Smoothness is 1/(1+MeanNeighbourDifference): 1 for a flat colour,
+	// a half where neighbours are a step apart on average, towards 0 for
+	// noise.
+	Smoothness float64 `json:"smoothness"`
+
+	// Hue counts the pixels in each 10 degrees of hue, starting at red.
+	// Greys have too little chroma for a hue and are counted on their own.
+	Hue  []int `json:"hue_histogram"`
+	Grey int   `json:"grey"`
+	// Luminance counts the pixels in 32 even bands of Rec. 709 luma, dark
+	// to light.
+	Luminance     []int   `json:"luminance_histogram"`
+	MeanLuminance float64 `json:"mean_luminance"`
+
+	// Fill is only known for images that were just made.
+	Fill *FillStats `json:"fill,omitempty"`
+}
+
+// ImageStats measures pic.
+func ImageStats(pic image.Image) *Stats {
+	b := pic.Bounds()
+	s := &Stats{
+		Width:     b.Dx(),
+		Height:    b.Dy(),
+		Hue:       make([]int, hueBins),
+		Luminance: make([]int, luminanceBins),
+	}
+	if s.Width == 0 || s.Height == 0 {
+		return s
+	}
+
+	// one row at a time, keeping the one above for the vertical neighbours
+	colours := make([]float64, 3*s.Width)
+	above := make([]float64, 3*s.Width)
+	var diffSum, lumSum float64
+	var pairs int
+	for y := 0; y < s.Height; y++ {
+		for x := 0; x < s.Width; x++ {
+			r, g, bl, _ := pic.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			c := colours[3*x : 3*x+3]
+			c[0], c[1], c[2] = float64(r>>8), float64(g>>8), float64(bl>>8)
+
+			if x > 0 {
+				d := rgbDistance(c, colours[3*x-3:3*x])
+				diffSum += d
+				s.MaxNeighbourDifference = math.Max(s.MaxNeighbourDifference, d)
+				pairs++
+			}
+			if y > 0 {
+				d := rgbDistance(c, above[3*x:3*x+3])
+				diffSum += d
+				s.MaxNeighbourDifference = math.Max(s.MaxNeighbourDifference, d)
+				pairs++
+			}
+
+			lum := 0.2126*c[0] + 0.7152*c[1] + 0.0722*c[2]
+			lumSum += lum
+			s.Luminance[minint(int(lum*luminanceBins/256), luminanceBins-1)]++
+
+			if hue, ok := hueOf(c[0], c[1], c[2]); ok {
+				s.Hue[minint(int(hue*hueBins/360), hueBins-1)]++
+			} else {
+				s.Grey++
+			}
+		}
+		colours, above = above, colours
+	}
+
+	if pairs > 0 {
+		s.MeanNeighbourDifference = diffSum / float64(pairs)
+	}
+	s.Smoothness = 1 / (1 + s.MeanNeighbourDifference)
+	s.MeanLuminance = lumSum / float64(s.Width*s.Height)
+	return s
+}
+
+func rgbDistance(a, b []float64) float64 {
+	dr, dg, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return math.Sqrt(dr*dr + dg*dg + db*db)
+}
+
+// hueOf gives the HSV hue of a colour in degrees, or false if it's grey.
+func hueOf(r, g, b float64) (float64, bool) {
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	chroma := max - min
+	if chroma < greyChroma {
+		return 0, false
+	}
+	var h float64
+	switch max {
+	case r:
+		h = math.Mod((g-b)/chroma, 6)
+	case g:
+		h = (b-r)/chroma + 2
+	default:
+		h = (r-g)/chroma + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h, true
+}
+
+// FillStats are gathered as the image is filled.
+type FillStats struct {
+	// TargetDistance counts the pixels by how far, rounded to a whole
+	// number, the colour they got was from the colour they wanted. Seeds
+	// aren't counted.
+	TargetDistance     []int   `json:"target_distance_histogram"`
+	MeanTargetDistance float64 `json:"mean_target_distance"`
+	MaxTargetDistance  float64 `json:"max_target_distance"`
+	// Exact is how many pixels got the colour they wanted.
+	Exact int `json:"exact"`
+
+	sum   float64
+	count int
+}
+
+func (fs *FillStats) reset() {
+	*fs = FillStats{}
+}
+
+// targetDistance is how far the colour a pixel got is from the colour it
+// wanted.
+func targetDistance(target, chosen Colour24) float64 {
+	return math.Sqrt(float64(distSqr(target.Red(), target.Green(), target.Blue(), chosen.Red(), chosen.Green(), chosen.Blue())))
+}
+
+func (fs *FillStats) add(target, chosen Colour24) {
+	d := targetDistance(target, chosen)
+	i := int(d + 0.5)
+	for len(fs.TargetDistance) <= i {
+		fs.TargetDistance = append(fs.TargetDistance, 0)
+	}
+	fs.TargetDistance[i]++
+	if i == 0 {
+		fs.Exact++
+	}
+	fs.sum += d
+	fs.count++
+	fs.MeanTargetDistance = fs.sum / float64(fs.count)
+	fs.MaxTargetDistance = math.Max(fs.MaxTargetDistance, d)
+}
@@ -0,0 +1,117 @@
+package pixelart
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// StreamOptions describe a FrameStream.
+type StreamOptions struct {
+	// Format is "y4m" for YUV4MPEG2 with 4:4:4 full range chroma, or "rgb"
+	// for a text line of "RGB24 <width> <height> <fps>" followed by bare
+	// 24 bit frames.
+	Format string
+	FPS    int
+	// Scale resizes the frames; 0 leaves them at full size.
+	Scale float64
+}
+
+// FrameStream writes canvases as uncompressed video frames, for piping into
+// an encoder.
+type FrameStream struct {
+	w    *bufio.Writer
+	opts StreamOptions
+	size image.Point
+
+	buf []byte
+}
+
+func NewFrameStream(w io.Writer, opts StreamOptions) (*FrameStream, error) {
+	opts.Format = strings.ToLower(opts.Format)
+	if opts.Format != "y4m" && opts.Format != "rgb" {
+		return nil, fmt.Errorf("unknown stream format %q, expected y4m or rgb", opts.Format)
+	}
+	if opts.FPS < 1 {
+		opts.FPS = 1
+	}
+	return &FrameStream{w: bufio.NewWriterSize(w, 1<<20), opts: opts}, nil
+}
+
+// StreamFrameHook returns a FrameHook that writes a frame to s every every
+// pixels. The first write error is kept in *err and stops the stream.
+func StreamFrameHook(s *FrameStream, every int, err *error) FrameHook {
+	return FrameHook{
+		Every: every,
+		Frame: func(pic *image.RGBA, filled int) {
+			if *err == nil {
+				*err = s.WriteFrame(pic)
+			}
+		},
+	}
+}
+
+func (s *FrameStream) writeHeader(size image.Point) error {
+	var err error
+	switch s.opts.Format {
+	case "y4m":
+		_, err = fmt.Fprintf(s.w, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C444 XCOLORRANGE=FULL\n", size.X, size.Y, s.opts.FPS)
+	case "rgb":
+		_, err = fmt.Fprintf(s.w, "RGB24 %d %d %d\n", size.X, size.Y, s.opts.FPS)
+	}
+	return err
+}
+
+func (s *FrameStream) WriteFrame(pic image.Image) error {
+	frame := scaleImage(pic, s.opts.Scale)
+	b := frame.Bounds()
+
+	if s.size == (image.Point{}) {
+		s.size = b.Size()
+		if err := s.writeHeader(s.size); err != nil {
+			return err
+		}
+	} else if b.Size() != s.size {
+		return fmt.Errorf("frame is %v, the stream is %v", b.Size(), s.size)
+	}
+
+	n := b.Dx() * b.Dy()
+	if len(s.buf) != 3*n {
+		s.buf = make([]byte, 3*n)
+	}
+
+	switch s.opts.Format {
+	case "y4m":
+		if _, err := io.WriteString(s.w, "FRAME\n"); err != nil {
+			return err
+		}
+		// planar: all of Y, then Cb, then Cr
+		i := 0
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			p := frame.PixOffset(b.Min.X, y)
+			for x := b.Min.X; x < b.Max.X; x, p = x+1, p+4 {
+				s.buf[i], s.buf[n+i], s.buf[2*n+i] = color.RGBToYCbCr(frame.Pix[p], frame.Pix[p+1], frame.Pix[p+2])
+				i++
+			}
+		}
+	case "rgb":
+		i := 0
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			p := frame.PixOffset(b.Min.X, y)
+			for x := b.Min.X; x < b.Max.X; x, p = x+1, p+4 {
+				copy(s.buf[i:i+3], frame.Pix[p:p+3])
+				i += 3
+			}
+		}
+	}
+
+	_, err := s.w.Write(s.buf)
+	return err
+}
+
+func (s *FrameStream) Flush() error {
+	return s.w.Flush()
+}
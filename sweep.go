@@ -0,0 +1,280 @@
+package pixelart
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// SweepAxis is a setting to vary in a sweep, named as in Settings, and the
+// values it takes.
+type SweepAxis struct {
+	Name   string
+	Values []string
+}
+
+// unsweepable settings either don't change the picture or name files that
+// Sweep won't load.
+var unsweepable = map[string]bool{
+	"name": true, "tag": true, "ir": true, "cpus": true,
+	"seed-image": true, "palette": true,
+	"checkpoint": true, "checkpoint-interval": true, "resume": true,
+}
+
+// ParseSweepAxis reads "name=a,b,c" or a range, "name=from..to" in steps of
+// one or "name=from..to:step".
+func ParseSweepAxis(s string) (SweepAxis, error) {
+	var axis SweepAxis
+	eq := strings.IndexByte(s, '=')
+	if eq < 1 {
+		return axis, fmt.Errorf("sweep %q should look like name=a,b,c or name=from..to:step", s)
+	}
+	axis.Name, s = s[:eq], s[eq+1:]
+	if !IsSetting(axis.Name) || unsweepable[axis.Name] {
+		return axis, fmt.Errorf("cannot sweep %q, expected one of the settings that change the picture", axis.Name)
+	}
+
+	if dots := strings.Index(s, ".."); dots >= 0 {
+		from, to, step := s[:dots], s[dots+2:], "1"
+		if colon := strings.IndexByte(to, ':'); colon >= 0 {
+			to, step = to[:colon], to[colon+1:]
+		}
+		var err error
+		axis.Values, err = sweepRange(from, to, step)
+		if err != nil {
+			return axis, fmt.Errorf("sweep of %s: %v", axis.Name, err)
+		}
+	} else {
+		axis.Values = strings.Split(s, ",")
+	}
+
+	// catch bad values now rather than part way through the sweep
+	for _, v := range axis.Values {
+		if _, err := (Settings{axis.Name: v}).Args(NewGenerateArgs()); err != nil {
+			return axis, err
+		}
+	}
+	return axis, nil
+}
+
+func sweepRange(from, to, step string) ([]string, error) {
+	lo, err1 := strconv.ParseFloat(from, 64)
+	hi, err2 := strconv.ParseFloat(to, 64)
+	by, err3 := strconv.ParseFloat(step, 64)
+	if err1 != nil || err2 != nil || err3 != nil || by <= 0 || hi < lo {
+		return nil, fmt.Errorf("bad range %s..%s:%s", from, to, step)
+	}
+	n := int(math.Floor((hi-lo)/by+1e-9)) + 1
+	if n > 10000 {
+		return nil, fmt.Errorf("range %s..%s:%s has %d values", from, to, step, n)
+	}
+	values := make([]string, n)
+	for i := range values {
+		// round off the error that builds up adding fractional steps
+		v := math.Round((lo+float64(i)*by)*1e9) / 1e9
+		values[i] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return values, nil
+}
+
+// SweepSettings lists every combination of the axes' values, the last axis
+// changing fastest.
+func SweepSettings(axes []SweepAxis) []Settings {
+	combos := []Settings{{}}
+	for _, axis := range axes {
+		next := make([]Settings, 0, len(combos)*len(axis.Values))
+		for _, base := range combos {
+			for _, v := range axis.Values {
+				s := Settings{axis.Name: v}
+				for key, value := range base {
+					s[key] = value
+				}
+				next = append(next, s)
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// SweepRun is one combination of a sweep.
+type SweepRun struct {
+	Index    int
+	Settings Settings
+	Image    image.Image
+	Elapsed  time.Duration
+	Err      error
+
+	// Cell is where ContactSheet put the run's picture, and File where the
+	// caller saved it, if anywhere; both end up in the CSV index.
+	Cell image.Rectangle
+	File string
+}
+
+// Sweep generates base with each combination of the axes' settings,
+// parallel runs at a time. Progress, Update and Frames aren't called for
+// the runs; done is, from the run's goroutine, as each one finishes.
+func Sweep(ctx context.Context, base GenerateArgs, axes []SweepAxis, parallel int, done func(run *SweepRun)) []*SweepRun {
+	if parallel < 1 {
+		parallel = 1
+	}
+	base.Update, base.Progress, base.Frames = nil, nil, nil
+	base.FillOrder, base.FillStats, base.Preview = nil, nil, nil
+	base.CheckpointPath, base.ResumePath = "", ""
+
+	combos := SweepSettings(axes)
+	runs := make([]*SweepRun, len(combos))
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, s := range combos {
+		runs[i] = &SweepRun{Index: i, Settings: s}
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(run *SweepRun) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			start := time.Now()
+			args, err := run.Settings.Args(base)
+			if err == nil {
+				err = args.Validate()
+			}
+			if err == nil {
+				run.Image, err = Generate(ctx, args)
+			}
+			run.Err = err
+			run.Elapsed = time.Since(start)
+			if done != nil {
+				done(run)
+			}
+		}(runs[i])
+	}
+	wg.Wait()
+	return runs
+}
+
+// Label gives the swept settings of the run, one per line in the order
+// of axes.
+func (run *SweepRun) Label(axes []SweepAxis) []string {
+	lines := make([]string, len(axes))
+	for i, axis := range axes {
+		lines[i] = axis.Name + "=" + run.Settings[axis.Name]
+	}
+	return lines
+}
+
+// ContactSheet lays the runs out cols to a row, each scaled to fit cell
+// pixels square and labelled underneath with its settings. Failed runs get
+// an empty cell with the error in place of the picture.
+func ContactSheet(runs []*SweepRun, axes []SweepAxis, cols, cell int) *image.RGBA {
+	const pad, lineHeight = 4, 13
+	face := basicfont.Face7x13
+	if cols < 1 {
+		cols = int(math.Ceil(math.Sqrt(float64(len(runs)))))
+	}
+	if cols < 1 {
+		cols = 1
+	}
+	rows := (len(runs) + cols - 1) / cols
+	labelHeight := lineHeight*maxint(1, len(axes)) + pad
+	// widen the columns rather than let long labels run into each other
+	width := cell
+	for _, run := range runs {
+		for _, line := range run.Label(axes) {
+			width = maxint(width, font.MeasureString(face, line).Ceil())
+		}
+	}
+	pitchX, pitchY := width+pad, cell+labelHeight+pad
+
+	sheet := image.NewRGBA(image.Rect(0, 0, cols*pitchX+pad, rows*pitchY+pad))
+	draw.Draw(sheet, sheet.Bounds(), image.White, image.Point{}, draw.Src)
+
+	text := func(lines []string, x, y int, c color.Color) {
+		d := font.Drawer{Dst: sheet, Src: image.NewUniform(c), Face: face}
+		for i, line := range lines {
+			d.Dot = fixed.P(x, y+(i+1)*lineHeight-3)
+			d.DrawString(line)
+		}
+	}
+
+	for i, run := range runs {
+		x := pad + (i%cols)*pitchX
+		y := pad + (i/cols)*pitchY
+		if run.Image == nil {
+			run.Cell = image.Rect(x, y, x+cell, y+cell)
+			draw.Draw(sheet, run.Cell, image.NewUniform(color.Gray{0xDD}), image.Point{}, draw.Src)
+			msg := "failed"
+			if run.Err != nil {
+				msg = run.Err.Error()
+			}
+			text(wrapText(msg, cell/7), x+2, y+2, color.RGBA{0xC0, 0, 0, 0xFF})
+		} else {
+			pic := Thumbnail(run.Image, cell)
+			run.Cell = pic.Bounds().Add(image.Pt(x, y))
+			draw.Draw(sheet, run.Cell, pic, pic.Bounds().Min, draw.Src)
+		}
+		text(run.Label(axes), x, y+cell+pad/2, color.Black)
+	}
+	return sheet
+}
+
+// wrapText breaks s into lines of at most width characters.
+func wrapText(s string, width int) (lines []string) {
+	for width > 0 && len(s) > width {
+		lines = append(lines, s[:width])
+		s = s[width:]
+	}
+	return append(lines, s)
+}
+
+// WriteSweepIndex writes a CSV line for each run: its index, the swept
+// settings, where it is on the contact sheet, the file it was saved to, how
+// long it took and any error.
+func WriteSweepIndex(w io.Writer, runs []*SweepRun, axes []SweepAxis) error {
+	cw := csv.NewWriter(w)
+	header := []string{"index"}
+	for _, axis := range axes {
+		header = append(header, axis.Name)
+	}
+	header = append(header, "x", "y", "width", "height", "file", "seconds", "error")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	sorted := append([]*SweepRun(nil), runs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Index < sorted[j].Index })
+	for _, run := range sorted {
+		record := []string{strconv.Itoa(run.Index)}
+		for _, axis := range axes {
+			record = append(record, run.Settings[axis.Name])
+		}
+		errText := ""
+		if run.Err != nil {
+			errText = run.Err.Error()
+		}
+		record = append(record,
+			strconv.Itoa(run.Cell.Min.X), strconv.Itoa(run.Cell.Min.Y),
+			strconv.Itoa(run.Cell.Dx()), strconv.Itoa(run.Cell.Dy()),
+			run.File, strconv.FormatFloat(run.Elapsed.Seconds(), 'f', 3, 64), errText)
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
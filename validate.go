@@ -0,0 +1,142 @@
+package pixelart
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// FieldError is a problem with one setting of a run. Field is the setting's
+// name, as in Settings and the command line flags.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors are all the problems found with a run's settings, in the
+// order they were found.
+type ValidationErrors []*FieldError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Field returns the first problem with the setting named field, or nil.
+func (errs ValidationErrors) Field(field string) *FieldError {
+	for _, e := range errs {
+		if e.Field == field {
+			return e
+		}
+	}
+	return nil
+}
+
+func (errs *ValidationErrors) add(field, format string, v ...interface{}) {
+	*errs = append(*errs, &FieldError{Field: field, Message: fmt.Sprintf(format, v...)})
+}
+
+// AsValidationErrors lists the field errors in err, which may be a
+// ValidationErrors or a single FieldError. Other errors give nil.
+func AsValidationErrors(err error) ValidationErrors {
+	switch e := err.(type) {
+	case ValidationErrors:
+		return e
+	case *FieldError:
+		return ValidationErrors{e}
+	}
+	return nil
+}
+
+// Validate checks args holds settings Generate can make an image from. It
+// returns a ValidationErrors naming every setting that's out of range, or
+// nil. A run that resumes takes its image settings from the checkpoint, so
+// only the settings that aren't should be checked before reading it.
+func (args GenerateArgs) Validate() error {
+	var errs ValidationErrors
+
+	sizeOK := true
+	if args.Width < 1 || args.Width > MaxWidth {
+		errs.add("width", "should be between 1 and %d", MaxWidth)
+		sizeOK = false
+	}
+	if args.Height < 1 || args.Height > MaxHeight {
+		errs.add("height", "should be between 1 and %d", MaxHeight)
+		sizeOK = false
+	}
+	if args.SeedImage != nil {
+		b := args.SeedImage.Bounds()
+		if b.Dx() < 1 || b.Dy() < 1 || b.Dx() > MaxWidth || b.Dy() > MaxHeight {
+			errs.add("seed-image", "image is %dx%d, outside 1x1 to %dx%d", b.Dx(), b.Dy(), MaxWidth, MaxHeight)
+			sizeOK = false
+		} else if sizeOK && !b.In(image.Rect(0, 0, args.Width, args.Height)) {
+			// its pixels seed the canvas where they sit
+			errs.add("seed-image", "image doesn't fit in the %dx%d canvas", args.Width, args.Height)
+			sizeOK = false
+		}
+	}
+
+	if args.ColourBasis > BRG {
+		errs.add("colour-basis", "unknown colour basis %d", args.ColourBasis)
+	}
+	for _, c := range []int{args.StartRed, args.StartGreen, args.StartBlue} {
+		if c < 0 || c > 0xFF {
+			errs.add("seed", "colour channels should be between 0 and 255")
+			break
+		}
+	}
+
+	if sizeOK {
+		// the seed image is scanned out from seed-x,seed-y, so they're in it
+		bounds := image.Rect(0, 0, args.Width, args.Height)
+		if args.SeedImage != nil {
+			bounds = args.SeedImage.Bounds()
+		}
+		if args.StartX < bounds.Min.X || args.StartX >= bounds.Max.X {
+			errs.add("seed-x", "should be between %d and %d", bounds.Min.X, bounds.Max.X-1)
+		}
+		if args.StartY < bounds.Min.Y || args.StartY >= bounds.Max.Y {
+			errs.add("seed-y", "should be between %d and %d", bounds.Min.Y, bounds.Max.Y-1)
+		}
+		for _, sp := range args.Seeds {
+			if !sp.Pt.In(bounds) {
+				errs.add("seeds", "seed at %d,%d is outside the image", sp.Pt.X, sp.Pt.Y)
+				break
+			}
+		}
+	}
+
+	if args.Blur < 1 || args.Blur > 50 {
+		errs.add("blur", "should be between 1 and 50")
+	}
+	if args.ChanSize < 8 {
+		errs.add("chan", "should be at least 8")
+	}
+	if args.Echospace < 0 || args.Echospace > 1 {
+		errs.add("es", "should be between 0 and 1")
+	}
+	if args.SeedRejectionRate < 0 || args.SeedRejectionRate > 1 {
+		errs.add("seed-rr", "should be between 0 and 1")
+	}
+	if args.ChromaColour < 0 || args.ChromaColour > 0xFFFFFF {
+		errs.add("seed-chroma-key", "should be a colour between 0x000000 and 0xFFFFFF")
+	}
+	if args.UpdateFreq < 1 || args.UpdateFreq > 4096 {
+		errs.add("update-freq", "should be between 1 and 4096")
+	}
+	if args.CheckpointPath != "" && args.CheckpointInterval <= 0 {
+		errs.add("checkpoint-interval", "should be more than 0")
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
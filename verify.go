@@ -0,0 +1,217 @@
+package pixelart
+
+import (
+	"fmt"
+	"image"
+	"sort"
+)
+
+// VerifyOptions say what Verify checks an image against. With no Palette
+// the colours to use are those of the RGB cube at Bits per channel, which
+// are the ones whose low 8-Bits bits are all zero.
+type VerifyOptions struct {
+	Palette image.Image
+	Bits    int
+	// Limit caps the colours listed in each part of the report; the counts
+	// are always complete. 0 lists none, <0 lists all of them.
+	Limit int
+}
+
+// ColourCount is a colour, as 0xRRGGBB, and how many pixels have it.
+type ColourCount struct {
+	Colour string `json:"colour"`
+	Count  int    `json:"count"`
+}
+
+// Verification is the report Verify makes on an image.
+type Verification struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Universe is the number of colours there are to use, in the palette or
+	// the cube.
+	Universe int `json:"universe"`
+	Distinct int `json:"distinct"`
+
+	// Exact is true if every colour of the universe is used exactly once.
+	Exact bool `json:"exact"`
+
+	// Duplicated colours are used by more than one pixel, DuplicatePixels
+	// being the pixels beyond the first of each.
+	Duplicated      int `json:"duplicated"`
+	DuplicatePixels int `json:"duplicate_pixels"`
+	// Missing colours of the universe aren't used at all.
+	Missing int `json:"missing"`
+	// Outside colours aren't in the universe, and OutsidePixels have them.
+	Outside       int `json:"outside"`
+	OutsidePixels int `json:"outside_pixels"`
+
+	Duplicates     []ColourCount `json:"duplicates,omitempty"`
+	MissingColours []string      `json:"missing_colours,omitempty"`
+	OutsideColours []ColourCount `json:"outside_colours,omitempty"`
+}
+
+func hexColour(c uint32) string {
+	return fmt.Sprintf("0x%06X", c)
+}
+
+// imageColours lists the colour of every pixel as 0xRRGGBB.
+func imageColours(pic image.Image) []uint32 {
+	b := pic.Bounds()
+	colours := make([]uint32, 0, b.Dx()*b.Dy())
+	switch p := pic.(type) {
+	case *image.RGBA:
+		// premultiplied, but we only care about opaque pictures
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			row := p.Pix[p.PixOffset(b.Min.X, y):p.PixOffset(b.Max.X, y)]
+			for i := 0; i < len(row); i += 4 {
+				colours = append(colours, uint32(row[i])<<16|uint32(row[i+1])<<8|uint32(row[i+2]))
+			}
+		}
+	case *image.NRGBA:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			row := p.Pix[p.PixOffset(b.Min.X, y):p.PixOffset(b.Max.X, y)]
+			for i := 0; i < len(row); i += 4 {
+				colours = append(colours, uint32(row[i])<<16|uint32(row[i+1])<<8|uint32(row[i+2]))
+			}
+		}
+	default:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, g, bl, _ := pic.At(x, y).RGBA()
+				colours = append(colours, (r>>8)<<16|(g>>8)<<8|bl>>8)
+			}
+		}
+	}
+	return colours
+}
+
+// sortedDistinct sorts colours and squeezes out the repeats.
+func sortedDistinct(colours []uint32) []uint32 {
+	sort.Slice(colours, func(i, j int) bool { return colours[i] < colours[j] })
+	out := colours[:0]
+	for i, c := range colours {
+		if i == 0 || c != colours[i-1] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Verify checks that pic uses each colour of the palette or cube in opts
+// exactly once.
+func Verify(pic image.Image, opts VerifyOptions) (*Verification, error) {
+	var palette []uint32
+	var inCube func(c uint32) bool
+	if opts.Palette != nil {
+		palette = sortedDistinct(imageColours(opts.Palette))
+	} else {
+		if opts.Bits == 0 {
+			opts.Bits = 8
+		}
+		if opts.Bits < 1 || opts.Bits > 8 {
+			return nil, fmt.Errorf("cube bit depth %d is outside 1 to 8", opts.Bits)
+		}
+		low := uint32(1)<<uint(8-opts.Bits) - 1
+		mask := low<<16 | low<<8 | low
+		inCube = func(c uint32) bool { return c&mask == 0 }
+	}
+	inUniverse := func(c uint32) bool {
+		if inCube != nil {
+			return inCube(c)
+		}
+		i := sort.Search(len(palette), func(i int) bool { return palette[i] >= c })
+		return i < len(palette) && palette[i] == c
+	}
+
+	b := pic.Bounds()
+	v := &Verification{Width: b.Dx(), Height: b.Dy()}
+	if inCube != nil {
+		v.Universe = 1 << uint(3*opts.Bits)
+	} else {
+		v.Universe = len(palette)
+	}
+	listed := func(n int) bool { return opts.Limit < 0 || n < opts.Limit }
+
+	colours := imageColours(pic)
+	sort.Slice(colours, func(i, j int) bool { return colours[i] < colours[j] })
+	var used []uint32
+	for i := 0; i < len(colours); {
+		j := i
+		for j < len(colours) && colours[j] == colours[i] {
+			j++
+		}
+		c, n := colours[i], j-i
+		i = j
+
+		v.Distinct++
+		if !inUniverse(c) {
+			v.Outside++
+			v.OutsidePixels += n
+			v.OutsideColours = append(v.OutsideColours, ColourCount{hexColour(c), n})
+			continue
+		}
+		used = append(used, c)
+		if n > 1 {
+			v.Duplicated++
+			v.DuplicatePixels += n - 1
+			v.Duplicates = append(v.Duplicates, ColourCount{hexColour(c), n})
+		}
+	}
+	// the most repeated first, they're the interesting ones
+	v.Duplicates = mostCommon(v.Duplicates, opts.Limit)
+	v.OutsideColours = mostCommon(v.OutsideColours, opts.Limit)
+
+	v.Missing = v.Universe - len(used)
+	if v.Missing > 0 && opts.Limit != 0 {
+		// walk the universe alongside the used colours, both in order
+		k := 0
+		eachColour(palette, opts.Bits, func(c uint32) bool {
+			for k < len(used) && used[k] < c {
+				k++
+			}
+			if k < len(used) && used[k] == c {
+				return true
+			}
+			v.MissingColours = append(v.MissingColours, hexColour(c))
+			return listed(len(v.MissingColours))
+		})
+	}
+
+	v.Exact = v.Duplicated == 0 && v.Missing == 0 && v.Outside == 0
+	return v, nil
+}
+
+// mostCommon sorts counts by count, highest first, and keeps limit of them.
+func mostCommon(counts []ColourCount, limit int) []ColourCount {
+	sort.SliceStable(counts, func(i, j int) bool { return counts[i].Count > counts[j].Count })
+	if limit >= 0 && len(counts) > limit {
+		counts = counts[:limit]
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+	return counts
+}
+
+// eachColour calls visit with the colours of palette, or of the cube at bits
+// per channel if there's no palette, in order, until it returns false.
+func eachColour(palette []uint32, bits int, visit func(c uint32) bool) {
+	if palette != nil {
+		for _, c := range palette {
+			if !visit(c) {
+				return
+			}
+		}
+		return
+	}
+	step := uint32(1) << uint(8-bits)
+	for r := uint32(0); r < 256; r += step {
+		for g := uint32(0); g < 256; g += step {
+			for b := uint32(0); b < 256; b += step {
+				if !visit(r<<16 | g<<8 | b) {
+					return
+				}
+			}
+		}
+	}
+}